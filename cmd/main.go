@@ -189,7 +189,7 @@ func main() {
 			var nextCmd string
 			err := survey.AskOne(&survey.Input{
 				Message: promptPS1,
-				Help:    "Supported commands: [support_bundle] [try_reconnect] [basic_info] [vi_events] [exit] [full_help]",
+				Help:    "Supported commands: [support_bundle] [try_reconnect] [basic_info] [vi_events] [vm_info] [vm_snapshots] [ds_files] [ds_acquire] [vm_logs] [sessions] [tags_libraries] [host_files] [host_logs] [exit] [full_help]",
 			}, &nextCmd, survey.WithValidator(survey.Required))
 			if err != nil {
 				log.Fatalln(err)
@@ -214,6 +214,33 @@ func main() {
 			case "basic_info":
 				subcmds.RetrieveBasicInformation()
 				continue
+			case "vm_info":
+				subcmds.RetrieveVMInfo()
+				continue
+			case "vm_snapshots":
+				subcmds.RetrieveVMSnapshots()
+				continue
+			case "ds_files":
+				subcmds.RetrieveDatastoreFiles()
+				continue
+			case "ds_acquire":
+				subcmds.AcquireDatastoreFiles()
+				continue
+			case "vm_logs":
+				subcmds.RetrieveVMLogs()
+				continue
+			case "sessions":
+				subcmds.RetrieveSessions()
+				continue
+			case "tags_libraries":
+				subcmds.RetrieveTagsAndLibraries()
+				continue
+			case "host_files":
+				subcmds.CollectHostFiles()
+				continue
+			case "host_logs":
+				subcmds.RetrieveHostLogs()
+				continue
 			default:
 				fmt.Println("not implemented.")
 			}
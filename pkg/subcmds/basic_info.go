@@ -2,6 +2,7 @@ package subcmds
 
 import (
 	"encoding/json"
+	"github.com/AlecAivazis/survey/v2"
 	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
 	log "github.com/sirupsen/logrus"
 	"github.com/vmware/govmomi/list"
@@ -37,12 +38,28 @@ func RetrieveBasicInformation() {
 	// processing if only vcenter
 	if vsphere_api.GlobalClient.IsVCenter() {
 		log.Infoln("vcenter determined. execute vcsa-specific method.")
+		// probing connects LDAPS servers from this host, which may be unwanted or leave traces, ask first
+		probeLDAPS := false
+		err = survey.AskOne(&survey.Confirm{
+			Message: "Connect LDAPS servers of identity sources to record certificates they present?",
+			Help: "Certificates trusted by SSO are always collected via SSO admin API, this additionally " +
+				"connects LDAPS servers directly from this host.",
+			Default: false,
+		}, &probeLDAPS)
+		if err != nil {
+			log.Errorln("User answer invalid, ldaps servers will not be probed: ", err)
+		}
 		// retrieve permissions list with role
 		err = vsphere_api.GlobalClient.ListPermissions(vcbi)
 		if err != nil {
 			log.Errorln("retrieve permissions list out, err: ", err)
 		}
 		log.Infoln("list permission finished.")
+		err = vsphere_api.AnalyzeRoles(vcbi)
+		if err != nil {
+			log.Errorln("analyze roles, err: ", err)
+		}
+		log.Infoln("analyze roles finished.")
 		// ---- must use vcenter specific token authentication ----
 		// get local and sso user
 		err = vsphere_api.GlobalClient.ListAllUsers(vcbi)
@@ -50,6 +67,22 @@ func RetrieveBasicInformation() {
 			log.Errorln("list all users, err: ", err)
 		}
 		log.Infoln("list all users finished.")
+		err = vsphere_api.GlobalClient.ListSSOGroupMembers(vcbi)
+		if err != nil {
+			log.Errorln("list sso group members, err: ", err)
+		}
+		log.Infoln("list sso group members finished.")
+		err = vsphere_api.GlobalClient.ListSSOPolicies(vcbi, probeLDAPS)
+		if err != nil {
+			log.Errorln("list sso policies, err: ", err)
+		}
+		log.Infoln("list sso policies finished.")
+		// effective permissions need roles, permissions and sso group members
+		err = vsphere_api.GlobalClient.ResolveEffectivePermissions(vcbi)
+		if err != nil {
+			log.Errorln("resolve effective permissions, err: ", err)
+		}
+		log.Infoln("resolve effective permissions finished.")
 		// ---- general procedures ----
 		// get max age
 		vcbi.EventMaxAge, err = vsphere_api.GlobalClient.GetEventMaxAge()
@@ -57,13 +90,59 @@ func RetrieveBasicInformation() {
 			log.Errorln("getevent-max-age-out, err:", err)
 		}
 		log.Infoln("get vcenter max event age finished.")
+		// dump all advanced settings and compare with baseline
+		err = vsphere_api.GlobalClient.ListAdvancedSettings(vcbi)
+		if err != nil {
+			log.Errorln("list advanced settings, err: ", err)
+		}
+		log.Infoln("list vcenter advanced settings finished.")
+		// scheduled tasks per entity, can be abused for persistence
+		err = vsphere_api.GlobalClient.ListScheduledTasks(vcbi)
+		if err != nil {
+			log.Errorln("list scheduled tasks, err: ", err)
+		}
+		log.Infoln("list scheduled tasks finished.")
+		// extensions and client plugins
+		err = vsphere_api.GlobalClient.ListExtensions(vcbi)
+		if err != nil {
+			log.Errorln("list extensions, err: ", err)
+		}
+		log.Infoln("list extensions finished.")
+		// distributed switches, port groups and port mirroring
+		err = vsphere_api.GlobalClient.ListDistributedSwitches(vcbi)
+		if err != nil {
+			log.Errorln("list distributed switches, err: ", err)
+		}
+		log.Infoln("list distributed switches finished.")
+		// cluster configuration and host profiles
+		err = vsphere_api.GlobalClient.ListClusters(vcbi)
+		if err != nil {
+			log.Errorln("list clusters, err: ", err)
+		}
+		log.Infoln("list clusters finished.")
+		err = vsphere_api.GlobalClient.ListVCCertificates(vcbi)
+		if err != nil {
+			log.Errorln("list certificates, err: ", err)
+		}
+		log.Infoln("list certificates finished.")
+		// appliance management api, ssh/shell access, local accounts, syslog, ntp, firewall, backup, services
+		err = vsphere_api.GlobalClient.ListApplianceConfig(vcbi)
+		if err != nil {
+			log.Errorln("list appliance configuration, err: ", err)
+		}
+		log.Infoln("list appliance configuration finished.")
 	}
+	// alarm definitions and triggered alarms, events are only collected on vcenter
+	err = vsphere_api.GlobalClient.ListAlarms(vcbi)
+	if err != nil {
+		log.Errorln("list alarms, err: ", err)
+	}
+	log.Infoln("list alarms finished.")
 	// if: standalone host, only singleHost should be used, do not use esxi host from List method.
 	// else: for each esx host, execute other methods.
 	err = vsphere_api.GlobalClient.RetrieveESXiHostBasicInfo(vcbi)
 	if err != nil {
 		log.Errorln("retr esxi info fail, err:", err)
-		return
 	}
 	log.Infoln("retr esxi info finished.")
 	// marshal vcbi and save
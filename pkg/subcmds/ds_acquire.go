@@ -0,0 +1,87 @@
+package subcmds
+
+import (
+	"fmt"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"strconv"
+	"strings"
+)
+
+type dsAcquireQuery struct {
+	DSList    []int  `survey:"selectedDS_list"`
+	PathGlobs string `survey:"path_globs"`
+	MaxSizeMB string `survey:"max_size_mb"`
+}
+
+func AcquireDatastoreFiles() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	survAns := &dsAcquireQuery{
+		DSList: make([]int, 0),
+	}
+	selectedDS, err := askDatastoreSelection(&survAns.DSList)
+	if err != nil {
+		log.Errorln("datastore selection failed: ", err)
+		return
+	}
+	survQes := []*survey.Question{
+		{
+			Name: "path_globs",
+			Prompt: &survey.Input{
+				Message: "Datastore paths or globs to acquire? (use | as seperator)",
+				Help: "Relative to datastore root, Example: \"vm01/vm01.vmx|vm01/vmware*.log|*.args\". " +
+					"Folder part narrows down search, file name part is glob.",
+			},
+			Validate: survey.Required,
+		},
+		{
+			Name: "max_size_mb",
+			Prompt: &survey.Input{
+				Message: "Max file size (MB) without confirmation?",
+				Default: "100",
+				Help:    "Files larger than this will only be downloaded if you confirm it one by one.",
+			},
+			Validate: survey.Required,
+		},
+	}
+	err = survey.Ask(survQes, survAns)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	maxSizeMB, err := strconv.ParseInt(survAns.MaxSizeMB, 10, 64)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	log.Debugln("Datastore Acquire, User Query Answer: ", survAns)
+	pathGlobs := make([]string, 0)
+	for _, v := range strings.Split(survAns.PathGlobs, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			pathGlobs = append(pathGlobs, v)
+		}
+	}
+	confirmFn := func(f *vsphere_api.DSFileEntry) bool {
+		confirmed := false
+		err := survey.AskOne(&survey.Confirm{
+			Message: fmt.Sprintf("%s is %d MB, still download?", f.Path, f.Size>>20),
+			Default: false,
+		}, &confirmed)
+		if err != nil {
+			log.Errorln("User answer invalid: ", err)
+			return false
+		}
+		return confirmed
+	}
+	err = vsphere_api.GlobalClient.AcquireDatastoreFiles(selectedDS, pathGlobs, maxSizeMB<<20, confirmFn)
+	if err != nil {
+		log.Errorln("acquire datastore files err: ", err)
+		return
+	}
+	log.Infoln("successfully finished ds_acquire.")
+	return
+}
@@ -0,0 +1,112 @@
+package subcmds
+
+import (
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"github.com/vmware/govmomi/vim25/types"
+	"path"
+	"strconv"
+	"time"
+)
+
+type dsFilesQuery struct {
+	DSList     []int  `survey:"selectedDS_list"`
+	PathGlob   string `survey:"path_glob"`
+	RecentDays string `survey:"recent_days"`
+}
+
+// askDatastoreSelection list datastores from server and ask user to select, empty selection means all.
+func askDatastoreSelection(dsIdxList *[]int) ([]list.Element, error) {
+	err := vsphere_api.GlobalClient.ListDatastores()
+	if err != nil {
+		return nil, err
+	}
+	allDS, err := vsphere_api.GlobalClient.GetCtxData("dsList")
+	if err != nil {
+		return nil, err
+	}
+	tmpDsLst := allDS.([]list.Element)
+	dsSelectOptions := make([]string, len(tmpDsLst))
+	for i := range tmpDsLst {
+		dsSelectOptions[i] = tmpDsLst[i].Path
+	}
+	err = survey.AskOne(&survey.MultiSelect{
+		Message:  "Select Datastore: (if all, press enter, do not select anything)",
+		Options:  dsSelectOptions,
+		PageSize: 10,
+	}, dsIdxList)
+	if err != nil {
+		return nil, err
+	}
+	// append selected datastore to list, note: careful with empty selection
+	if len(*dsIdxList) == 0 {
+		return tmpDsLst, nil
+	}
+	res := make([]list.Element, 0, len(*dsIdxList))
+	for _, v := range *dsIdxList {
+		res = append(res, tmpDsLst[v])
+	}
+	return res, nil
+}
+
+func RetrieveDatastoreFiles() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	survAns := &dsFilesQuery{
+		DSList: make([]int, 0),
+	}
+	selectedDS, err := askDatastoreSelection(&survAns.DSList)
+	if err != nil {
+		log.Errorln("datastore selection failed: ", err)
+		return
+	}
+	dsRefs := make([]types.ManagedObjectReference, len(selectedDS))
+	dsNames := make([]string, len(selectedDS))
+	for i := range selectedDS {
+		dsRefs[i] = selectedDS[i].Object.Reference()
+		dsNames[i] = path.Base(selectedDS[i].Path)
+	}
+	survQes := []*survey.Question{
+		{
+			Name: "path_glob",
+			Prompt: &survey.Input{
+				Message: "Path glob to match?",
+				Default: "*",
+				Help: "Matched against file name, search is recursive. If folder is included, search will start " +
+					"from that folder, Example: \"*.args\" or \"vm01/*.log\".",
+			},
+			Validate: survey.Required,
+		},
+		{
+			Name: "recent_days",
+			Prompt: &survey.Input{
+				Message: "Files modified within how many days should be reported as recent?",
+				Default: "7",
+			},
+			Validate: survey.Required,
+		},
+	}
+	err = survey.Ask(survQes, survAns)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	recentDays, err := strconv.Atoi(survAns.RecentDays)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	log.Debugln("Datastore Files, User Query Answer: ", survAns)
+	err = vsphere_api.GlobalClient.CollectDatastoreFiles(dsRefs, dsNames, survAns.PathGlob,
+		time.Now().AddDate(0, 0, -recentDays))
+	if err != nil {
+		log.Errorln("collect datastore files err: ", err)
+		return
+	}
+	log.Infoln("successfully finished ds_files.")
+	return
+}
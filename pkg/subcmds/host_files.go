@@ -0,0 +1,85 @@
+package subcmds
+
+import (
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"strings"
+)
+
+type hostFilesQuery struct {
+	HostList    []int  `survey:"selectedHost_list"`
+	RemotePaths string `survey:"remote_paths"`
+}
+
+func askESXiHostSelection(hostIdxList *[]int) ([]list.Element, error) {
+	err := vsphere_api.GlobalClient.ListEsxiHost()
+	if err != nil {
+		return nil, err
+	}
+	allHosts, err := vsphere_api.GlobalClient.GetCtxData("esxiHostList")
+	if err != nil {
+		return nil, err
+	}
+	tmpHostLst := allHosts.([]list.Element)
+	hostSelectOptions := make([]string, len(tmpHostLst))
+	for i := range tmpHostLst {
+		hostSelectOptions[i] = tmpHostLst[i].Path
+	}
+	err = survey.AskOne(&survey.MultiSelect{
+		Message:  "Select ESXi Host: (if all, press enter, do not select anything)",
+		Options:  hostSelectOptions,
+		PageSize: 10,
+	}, hostIdxList)
+	if err != nil {
+		return nil, err
+	}
+	// note: careful with empty selection
+	if len(*hostIdxList) == 0 {
+		return tmpHostLst, nil
+	}
+	res := make([]list.Element, 0, len(*hostIdxList))
+	for _, v := range *hostIdxList {
+		res = append(res, tmpHostLst[v])
+	}
+	return res, nil
+}
+
+func CollectHostFiles() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	survAns := &hostFilesQuery{
+		HostList: make([]int, 0),
+	}
+	selectedHosts, err := askESXiHostSelection(&survAns.HostList)
+	if err != nil {
+		log.Errorln("esxi host selection failed: ", err)
+		return
+	}
+	err = survey.AskOne(&survey.Input{
+		Message: "Files on ESXi to collect? (use | as seperator)",
+		Default: strings.Join(vsphere_api.ESXHostFileDefaults, "|"),
+		Help:    "Absolute path on ESXi, only files under /etc are served by /host endpoint.",
+	}, &survAns.RemotePaths, survey.WithValidator(survey.Required))
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	log.Debugln("Host Files, User Query Answer: ", survAns)
+	remotePaths := make([]string, 0)
+	for _, v := range strings.Split(survAns.RemotePaths, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			remotePaths = append(remotePaths, v)
+		}
+	}
+	err = vsphere_api.GlobalClient.CollectHostFiles(selectedHosts, remotePaths)
+	if err != nil {
+		log.Errorln("collect host files err: ", err)
+		return
+	}
+	log.Infoln("successfully finished host_files.")
+	return
+}
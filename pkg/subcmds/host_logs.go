@@ -0,0 +1,73 @@
+package subcmds
+
+import (
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"strings"
+)
+
+type hostLogsQuery struct {
+	HostList       []int  `survey:"selectedHost_list"`
+	LogKeys        string `survey:"log_keys"`
+	ResumeManifest string `survey:"resume_manifest"`
+}
+
+func RetrieveHostLogs() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	survAns := &hostLogsQuery{
+		HostList: make([]int, 0),
+	}
+	// standalone esxi is the only host, no need to select
+	var selectedHosts []list.Element
+	if vsphere_api.GlobalClient.IsVCenter() {
+		var err error
+		selectedHosts, err = askESXiHostSelection(&survAns.HostList)
+		if err != nil {
+			log.Errorln("esxi host selection failed: ", err)
+			return
+		}
+	}
+	survQes := []*survey.Question{
+		{
+			Name: "log_keys",
+			Prompt: &survey.Input{
+				Message: "Logs to download? (use | as seperator)",
+				Default: strings.Join(vsphere_api.HostLogKeyDefaults, "|"),
+				Help: "Matched against log key, vCenter key prefix (\"vpxd\" of \"vpxd:vpxd-1.log\") or file name " +
+					"without extension (\"auth\" of \"/var/log/auth.log\"). Available ones are listed in manifest.",
+			},
+			Validate: survey.Required,
+		},
+		{
+			Name: "resume_manifest",
+			Prompt: &survey.Input{
+				Message: "Manifest of previous run to resume from? (leave empty to download from beginning)",
+				Help:    "Example: output/HostLogs_Manifest_1690000000.json",
+			},
+		},
+	}
+	err := survey.Ask(survQes, survAns)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	log.Debugln("Host Logs, User Query Answer: ", survAns)
+	logKeys := make([]string, 0)
+	for _, v := range strings.Split(survAns.LogKeys, "|") {
+		if v = strings.TrimSpace(v); v != "" {
+			logKeys = append(logKeys, v)
+		}
+	}
+	err = vsphere_api.GlobalClient.CollectHostLogs(selectedHosts, logKeys, strings.TrimSpace(survAns.ResumeManifest))
+	if err != nil {
+		log.Errorln("collect host logs err: ", err)
+		return
+	}
+	log.Infoln("successfully finished host_logs.")
+	return
+}
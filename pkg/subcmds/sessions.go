@@ -0,0 +1,61 @@
+package subcmds
+
+import (
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"net"
+	"strings"
+)
+
+// parseExpectedNetworks parse "|" separated CIDR or single IP address list.
+func parseExpectedNetworks(s string) ([]*net.IPNet, error) {
+	res := make([]*net.IPNet, 0)
+	for _, v := range strings.Split(s, "|") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			if ip := net.ParseIP(v); ip != nil && ip.To4() != nil {
+				v += "/32"
+			} else {
+				v += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, ipNet)
+	}
+	return res, nil
+}
+
+func RetrieveSessions() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	expectedNetsStr := ""
+	err := survey.AskOne(&survey.Input{
+		Message: "Expected source networks? (CIDR or IP, use | as separator, empty to skip IP check)",
+		Help:    "Example: \"10.0.0.0/8|192.168.1.10\", sessions from other addresses will be flagged.",
+	}, &expectedNetsStr)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	expectedNets, err := parseExpectedNetworks(expectedNetsStr)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	err = vsphere_api.GlobalClient.CollectSessions(expectedNets)
+	if err != nil {
+		log.Errorln("collect sessions err: ", err)
+		return
+	}
+	log.Infoln("successfully finished sessions.")
+	return
+}
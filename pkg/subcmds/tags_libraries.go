@@ -0,0 +1,24 @@
+package subcmds
+
+import (
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+)
+
+func RetrieveTagsAndLibraries() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	if !vsphere_api.GlobalClient.IsVCenter() {
+		log.Errorln("Current session is NOT connected to a valid vCenter. Unsupported operation.")
+		return
+	}
+	err := vsphere_api.GlobalClient.CollectTagsAndLibraries()
+	if err != nil {
+		log.Errorln("collect tags and libraries err: ", err)
+		return
+	}
+	log.Infoln("successfully finished tags_libraries.")
+	return
+}
@@ -0,0 +1,20 @@
+package subcmds
+
+import (
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+)
+
+func RetrieveVMInfo() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	err := vsphere_api.GlobalClient.CollectVMInfo()
+	if err != nil {
+		log.Errorln("collect vm info err: ", err)
+		return
+	}
+	log.Infoln("successfully finished vm_info.")
+	return
+}
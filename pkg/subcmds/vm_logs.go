@@ -0,0 +1,76 @@
+package subcmds
+
+import (
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+)
+
+type vmLogsQuery struct {
+	VMList []int `survey:"selectedVM_list"`
+	Parse  bool  `survey:"parse_logs"`
+}
+
+func RetrieveVMLogs() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	err := vsphere_api.GlobalClient.ListVirtualMachines()
+	if err != nil {
+		log.Errorln("list virtual machines err: ", err)
+		return
+	}
+	allVM, err := vsphere_api.GlobalClient.GetCtxData("vmList")
+	if err != nil {
+		log.Errorln("get virtual machine list err: ", err)
+		return
+	}
+	tmpVmLst := allVM.([]list.Element)
+	vmSelectOptions := make([]string, len(tmpVmLst))
+	for i := range tmpVmLst {
+		vmSelectOptions[i] = tmpVmLst[i].Path
+	}
+	survAns := &vmLogsQuery{
+		VMList: make([]int, 0),
+	}
+	survQes := []*survey.Question{
+		{
+			Name: "selectedVM_list",
+			Prompt: &survey.MultiSelect{
+				Message:  "Select Virtual Machine: (if all, press enter, do not select anything)",
+				Options:  vmSelectOptions,
+				PageSize: 10,
+			},
+		},
+		{
+			Name: "parse_logs",
+			Prompt: &survey.Confirm{
+				Message: "Parse downloaded logs into timestamped records?",
+				Default: true,
+			},
+		},
+	}
+	err = survey.Ask(survQes, survAns)
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	log.Debugln("VM Logs, User Query Answer: ", survAns)
+	// append selected vm to list, note: careful with empty selection
+	selectedVM := tmpVmLst
+	if len(survAns.VMList) != 0 {
+		selectedVM = make([]list.Element, 0, len(survAns.VMList))
+		for _, v := range survAns.VMList {
+			selectedVM = append(selectedVM, tmpVmLst[v])
+		}
+	}
+	err = vsphere_api.GlobalClient.CollectVMLogs(selectedVM, survAns.Parse)
+	if err != nil {
+		log.Errorln("collect vm logs err: ", err)
+		return
+	}
+	log.Infoln("successfully finished vm_logs.")
+	return
+}
@@ -0,0 +1,82 @@
+package subcmds
+
+import (
+	"errors"
+	"github.com/AlecAivazis/survey/v2"
+	"github.com/kmahyyg/DFIR4vSphere-go/pkg/vsphere_api"
+	log "github.com/sirupsen/logrus"
+	"time"
+)
+
+var (
+	ErrIncidentWindowInvalid = errors.New("incident window end is before start")
+)
+
+type incidentWindowQuery struct {
+	WindowStart string `survey:"window_start"`
+	WindowEnd   string `survey:"window_end"`
+}
+
+const incidentWindowLayout = "2006-01-02T15:04:05Z07:00"
+
+// askIncidentWindow ask user for incident window, default to last 30 days.
+func askIncidentWindow() (time.Time, time.Time, error) {
+	now := time.Now()
+	survAns := &incidentWindowQuery{}
+	survQes := []*survey.Question{
+		{
+			Name: "window_start",
+			Prompt: &survey.Input{
+				Message: "Incident window start? (RFC3339)",
+				Default: now.AddDate(0, 0, -30).Format(incidentWindowLayout),
+				Help:    "Example: \"2023-02-03T00:00:00+08:00\", operations after this time will be flagged.",
+			},
+			Validate: survey.Required,
+		},
+		{
+			Name: "window_end",
+			Prompt: &survey.Input{
+				Message: "Incident window end? (RFC3339)",
+				Default: now.Format(incidentWindowLayout),
+				Help:    "Example: \"2023-02-05T00:00:00+08:00\", operations before this time will be flagged.",
+			},
+			Validate: survey.Required,
+		},
+	}
+	err := survey.Ask(survQes, survAns)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	wStart, err := time.Parse(incidentWindowLayout, survAns.WindowStart)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	wEnd, err := time.Parse(incidentWindowLayout, survAns.WindowEnd)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if wEnd.Before(wStart) {
+		return time.Time{}, time.Time{}, ErrIncidentWindowInvalid
+	}
+	return wStart, wEnd, nil
+}
+
+func RetrieveVMSnapshots() {
+	if !vsphere_api.GlobalClient.IsLoggedIn() {
+		log.Errorln("Current session is NOT LOGGED IN. Run try_reconnect for retry.")
+		return
+	}
+	wStart, wEnd, err := askIncidentWindow()
+	if err != nil {
+		log.Errorln("User answer invalid: ", err)
+		return
+	}
+	log.Infoln("incident window: ", wStart.Format(time.RFC3339), " - ", wEnd.Format(time.RFC3339))
+	err = vsphere_api.GlobalClient.CollectVMSnapshots(wStart, wEnd)
+	if err != nil {
+		log.Errorln("collect vm snapshots err: ", err)
+		return
+	}
+	log.Infoln("successfully finished vm_snapshots.")
+	return
+}
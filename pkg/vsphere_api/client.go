@@ -12,6 +12,7 @@ import (
 	"github.com/vmware/govmomi/session/cache"
 	"github.com/vmware/govmomi/ssoadmin"
 	"github.com/vmware/govmomi/sts"
+	"github.com/vmware/govmomi/vapi/rest"
 	"github.com/vmware/govmomi/vim25"
 	"github.com/vmware/govmomi/vim25/methods"
 	"github.com/vmware/govmomi/vim25/soap"
@@ -36,6 +37,8 @@ var (
 type vSphereClient struct {
 	// ssoClient Usage
 	ssoClient *ssoadmin.Client
+	// restClient for vSphere Automation API, e.g. tags and content libraries
+	restClient *rest.Client
 	// soapURL for SDK
 	soapURL *url.URL
 	// skipTLS should be set here since it's always static and user defined it at very beginning
@@ -160,6 +163,23 @@ func (vsc *vSphereClient) Login2SSOMgmt() (*ssoadmin.Client, error) {
 	return vsc.ssoClient, nil
 }
 
+// Login2REST create a vSphere Automation API session with credentials of current session, session is reused
+// until Logout.
+func (vsc *vSphereClient) Login2REST() (*rest.Client, error) {
+	authCtx := context.Background()
+	if vsc.restClient != nil && vsc.restClient.Valid() {
+		return vsc.restClient, nil
+	}
+	rc := rest.NewClient(vsc.vmwSoapClient)
+	err := rc.Login(authCtx, vsc.soapURL.User)
+	if err != nil {
+		log.Errorln("rest client login failed, err:", err)
+		return nil, err
+	}
+	vsc.restClient = rc
+	return vsc.restClient, nil
+}
+
 // LoginViaPassword will try to log in using credentials, if Token is required, you may query STS, then
 // issue ticket or token yourself.
 func (vsc *vSphereClient) LoginViaPassword() (err error) {
@@ -207,6 +227,13 @@ func (vsc *vSphereClient) Logout() (err error) {
 			log.Errorln("ssoadmin client logout failed: ", err)
 		}
 	}
+	if vsc.restClient != nil {
+		err = vsc.restClient.Logout(context.Background())
+		if err != nil {
+			log.Errorln("rest client logout failed: ", err)
+		}
+		vsc.restClient = nil
+	}
 	return err
 }
 
@@ -0,0 +1,223 @@
+package vsphere_api
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrDatacenterOfDSNotFound = errors.New("cannot determine datacenter of datastore")
+	ErrUnsafeRemotePath       = errors.New("remote path contains parent directory reference")
+)
+
+type DSAcquireManifest struct {
+	StartedAt  time.Time               `json:"started_at"`
+	FinishedAt time.Time               `json:"finished_at"`
+	MaxSize    int64                   `json:"max_size"`
+	OutputDir  string                  `json:"output_dir"`
+	Files      []*DSAcquiredFile       `json:"files"`
+	Skipped    []*DSAcquireSkippedFile `json:"skipped,omitempty"`
+	PathGlobs  []string                `json:"path_globs"`
+	Datastores []string                `json:"datastores"`
+	Server     string                  `json:"server"`
+}
+
+type DSAcquiredFile struct {
+	Datastore      string     `json:"datastore"`
+	DatastoreRef   string     `json:"datastore_ref"`
+	RemotePath     string     `json:"remote_path"`
+	LocalPath      string     `json:"local_path"`
+	RemoteSize     int64      `json:"remote_size"`
+	DownloadedSize int64      `json:"downloaded_size"`
+	RemoteModTime  *time.Time `json:"remote_mod_time,omitempty"`
+	SHA256         string     `json:"sha256"`
+	MD5            string     `json:"md5"`
+	AcquiredAt     time.Time  `json:"acquired_at"`
+}
+
+type DSAcquireSkippedFile struct {
+	Datastore    string `json:"datastore"`
+	DatastoreRef string `json:"datastore_ref"`
+	RemotePath   string `json:"remote_path"`
+	RemoteSize   int64  `json:"remote_size"`
+	Reason       string `json:"reason"`
+}
+
+// datacenterPathOfDatastore find datacenter inventory path which contains datastore, dcPath is required by
+// /folder endpoint. Datacenter may be nested in folder, so the longest prefix wins.
+func (vsc *vSphereClient) datacenterPathOfDatastore(dsInvtPath string) (string, error) {
+	allDC, err := vsc.GetCtxData("dcList")
+	if err != nil {
+		err = vsc.ListDataCenter()
+		if err != nil {
+			return "", err
+		}
+		allDC, err = vsc.GetCtxData("dcList")
+		if err != nil {
+			return "", err
+		}
+	}
+	res := ""
+	for _, dc := range allDC.([]list.Element) {
+		if strings.HasPrefix(dsInvtPath, dc.Path+"/") && len(dc.Path) > len(res) {
+			res = dc.Path
+		}
+	}
+	if res == "" {
+		return "", ErrDatacenterOfDSNotFound
+	}
+	return strings.TrimPrefix(res, "/"), nil
+}
+
+// datastoreFolderURL build /folder endpoint URL of file, filePath is relative to datastore root.
+func (vsc *vSphereClient) datastoreFolderURL(dcPath string, dsName string, filePath string) *url.URL {
+	u := vsc.vmwSoapClient.URL()
+	return &url.URL{
+		Scheme: u.Scheme,
+		Host:   u.Host,
+		Path:   "/folder/" + strings.TrimPrefix(filePath, "/"),
+		RawQuery: url.Values{
+			"dcPath": []string{dcPath},
+			"dsName": []string{dsName},
+		}.Encode(),
+	}
+}
+
+// dsRelativePath strip "[datastore] " prefix from datastore path
+func dsRelativePath(dsPath string) string {
+	if idx := strings.Index(dsPath, "] "); idx != -1 && strings.HasPrefix(dsPath, "[") {
+		return strings.TrimPrefix(dsPath[idx+2:], "/")
+	}
+	return strings.TrimPrefix(dsPath, "/")
+}
+
+// AcquireDatastoreFiles expand globs on each datastore, download matched files through /folder endpoint using
+// current session, hash while streaming and write manifest. Files larger than maxSize are only downloaded
+// if confirmOversize returns true.
+func (vsc *vSphereClient) AcquireDatastoreFiles(dsElems []list.Element, pathGlobs []string, maxSize int64,
+	confirmOversize func(f *DSFileEntry) bool) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	manifest := &DSAcquireManifest{
+		StartedAt: time.Now(),
+		MaxSize:   maxSize,
+		OutputDir: filepath.Join("output", "DatastoreAcquire_"+strconv.FormatInt(time.Now().Unix(), 10)),
+		Files:     make([]*DSAcquiredFile, 0),
+		Skipped:   make([]*DSAcquireSkippedFile, 0),
+		PathGlobs: pathGlobs,
+		Server:    vsc.vmwSoapClient.URL().Host,
+	}
+	for _, dsElem := range dsElems {
+		dsName := path.Base(dsElem.Path)
+		dsRef := dsElem.Object.Reference()
+		// datastore name is only unique within datacenter, prefix local folder with managed object id
+		dsLocalDir := filepath.Join(manifest.OutputDir, dsRef.Value+"_"+safePathComponent(dsName))
+		manifest.Datastores = append(manifest.Datastores, dsName)
+		dcPath, err := vsc.datacenterPathOfDatastore(dsElem.Path)
+		if err != nil {
+			log.Errorln("datacenter of datastore ", dsName, ", err: ", err)
+			continue
+		}
+		for _, pGlob := range pathGlobs {
+			files, err := vsc.BrowseDatastoreFiles(dsRef, dsName, pGlob)
+			if err != nil {
+				log.Errorln("browse datastore ", dsName, " with ", pGlob, ", err: ", err)
+				continue
+			}
+			for _, f := range files {
+				if f.Type == "FolderFileInfo" {
+					continue
+				}
+				if f.Size > maxSize && (confirmOversize == nil || !confirmOversize(f)) {
+					manifest.Skipped = append(manifest.Skipped, &DSAcquireSkippedFile{
+						Datastore:    dsName,
+						DatastoreRef: dsRef.Value,
+						RemotePath:   f.Path,
+						RemoteSize:   f.Size,
+						Reason:       fmt.Sprintf("size %d exceeds limit %d, not confirmed", f.Size, maxSize),
+					})
+					log.Warnln("skipped oversize file: ", f.Path)
+					continue
+				}
+				localPath := filepath.Join(dsLocalDir, filepath.FromSlash(dsRelativePath(f.Path)))
+				acqF, err := vsc.acquireSingleDSFile(dcPath, dsRef.Value, f, localPath)
+				if err != nil {
+					manifest.Skipped = append(manifest.Skipped, &DSAcquireSkippedFile{
+						Datastore:    dsName,
+						DatastoreRef: dsRef.Value,
+						RemotePath:   f.Path,
+						RemoteSize:   f.Size,
+						Reason:       err.Error(),
+					})
+					log.Errorln("acquire file ", f.Path, ", err: ", err)
+					continue
+				}
+				manifest.Files = append(manifest.Files, acqF)
+				log.Infoln("acquired file: ", f.Path, " sha256: ", acqF.SHA256)
+			}
+		}
+	}
+	manifest.FinishedAt = time.Now()
+	fPath, err := SaveJSONOutput("DatastoreAcquire_Manifest", manifest)
+	if err != nil {
+		log.Errorln("save acquire manifest, err: ", err)
+		return err
+	}
+	log.Infoln("datastore acquire manifest stored in json: ", fPath)
+	return nil
+}
+
+// acquireSingleDSFile download a single datastore file to localPath, hashing while streaming.
+func (vsc *vSphereClient) acquireSingleDSFile(dcPath string, dsRef string, f *DSFileEntry,
+	localPath string) (*DSAcquiredFile, error) {
+	relPath := dsRelativePath(f.Path)
+	// remote path is controlled by server, do not let it escape output directory
+	for _, seg := range strings.Split(relPath, "/") {
+		if seg == ".." {
+			return nil, ErrUnsafeRemotePath
+		}
+	}
+	err := os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+	req, err := vsc.newDownloadRequest(vsc.datastoreFolderURL(dcPath, f.Datastore, relPath).String(), true)
+	if err != nil {
+		return nil, err
+	}
+	sha256H := sha256.New()
+	md5H := md5.New()
+	n, err := vsc.progressedDownload(req, localPath, sha256H, md5H)
+	if err != nil {
+		return nil, err
+	}
+	// keep remote modification time on local copy as well
+	if f.Modification != nil {
+		_ = os.Chtimes(localPath, *f.Modification, *f.Modification)
+	}
+	return &DSAcquiredFile{
+		Datastore:      f.Datastore,
+		DatastoreRef:   dsRef,
+		RemotePath:     f.Path,
+		LocalPath:      localPath,
+		RemoteSize:     f.Size,
+		DownloadedSize: n,
+		RemoteModTime:  f.Modification,
+		SHA256:         hex.EncodeToString(sha256H.Sum(nil)),
+		MD5:            hex.EncodeToString(md5H.Sum(nil)),
+		AcquiredAt:     time.Now(),
+	}, nil
+}
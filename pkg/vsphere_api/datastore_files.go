@@ -0,0 +1,245 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrUnexpectedTaskResult = errors.New("task result is not in expected type")
+)
+
+var (
+	// dsSuspiciousExtensions maps lower-case file extension to the reason it is flagged
+	dsSuspiciousExtensions = map[string]string{
+		".args":      "ESXiArgs encryption metadata",
+		".esxiargs":  "ESXiArgs encrypted file",
+		".encrypted": "possibly encrypted by ransomware",
+		".crypt":     "possibly encrypted by ransomware",
+		".locked":    "possibly encrypted by ransomware",
+		".enc":       "possibly encrypted by ransomware",
+		".lockbit":   "LockBit encrypted file",
+		".babyk":     "Babyk encrypted file",
+		".royal_u":   "Royal encrypted file",
+		".iso":       "uploaded ISO image",
+		".sh":        "shell script",
+		".py":        "python script",
+		".elf":       "executable binary",
+		".bin":       "executable binary",
+		".exe":       "windows executable",
+		".ps1":       "powershell script",
+		".html":      "possible ransom note",
+		".txt":       "possible ransom note",
+	}
+	// dsRansomNoteKeywords matches file base name in lower-case
+	dsRansomNoteKeywords = []string{"how_to", "readme", "ransom", "decrypt", "restore", "recover"}
+)
+
+type DSFileEntry struct {
+	Datastore    string     `json:"datastore"`
+	Path         string     `json:"path"`
+	Size         int64      `json:"size"`
+	Modification *time.Time `json:"modification,omitempty"`
+	Type         string     `json:"type"`
+	Owner        string     `json:"owner,omitempty"`
+}
+
+type DSSuspiciousFile struct {
+	*DSFileEntry
+	Reason string `json:"reason"`
+}
+
+type DSFileListSummary struct {
+	PathGlob       string              `json:"path_glob"`
+	RecentSince    time.Time           `json:"recent_since"`
+	FileCount      map[string]int      `json:"file_count_by_datastore"`
+	ExtensionCount map[string]int      `json:"file_count_by_extension"`
+	Suspicious     []*DSSuspiciousFile `json:"suspicious,omitempty"`
+	RecentModified []*DSFileEntry      `json:"recent_modified,omitempty"`
+}
+
+// BrowseDatastoreFiles search files matching pathGlob recursively in datastore. If pathGlob contains folder, search
+// will only start from that folder, e.g. "vm01/*.log" only search "vm01" folder and its sub-folders.
+func (vsc *vSphereClient) BrowseDatastoreFiles(dsRef types.ManagedObjectReference, dsName string,
+	pathGlob string) ([]*DSFileEntry, error) {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return nil, ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	ds := object.NewDatastore(vsc.vmwSoapClient, dsRef)
+	browser, err := ds.Browser(tmpCtx)
+	if err != nil {
+		return nil, err
+	}
+	searchDir, pattern := path.Split(pathGlob)
+	if pattern == "" {
+		pattern = "*"
+	}
+	searchSpec := &types.HostDatastoreBrowserSearchSpec{
+		Details: &types.FileQueryFlags{
+			FileType:     true,
+			FileSize:     true,
+			Modification: true,
+			FileOwner:    types.NewBool(true),
+		},
+		SearchCaseInsensitive: types.NewBool(true),
+		MatchPattern:          []string{pattern},
+		// typed query first, so the result will be typed as possible, FileQuery matches everything else
+		Query: []types.BaseFileQuery{&types.FolderFileQuery{}, &types.VmDiskFileQuery{}, &types.VmConfigFileQuery{},
+			&types.VmLogFileQuery{}, &types.VmNvramFileQuery{}, &types.VmSnapshotFileQuery{},
+			&types.IsoImageFileQuery{}, &types.FloppyImageFileQuery{}, &types.FileQuery{}},
+	}
+	dsPath := "[" + dsName + "] " + strings.TrimSuffix(searchDir, "/")
+	task, err := browser.SearchDatastoreSubFolders(tmpCtx, dsPath, searchSpec)
+	if err != nil {
+		return nil, err
+	}
+	taskRes, err := task.WaitForResult(tmpCtx, nil)
+	if err != nil {
+		return nil, err
+	}
+	searchRes, ok := taskRes.Result.(types.ArrayOfHostDatastoreBrowserSearchResults)
+	if !ok {
+		return nil, ErrUnexpectedTaskResult
+	}
+	res := make([]*DSFileEntry, 0)
+	for _, folder := range searchRes.HostDatastoreBrowserSearchResults {
+		// folder path of datastore root is "[ds] ", sub-folder may or may not end with "/"
+		folderPath := folder.FolderPath
+		if !strings.HasSuffix(folderPath, "/") && !strings.HasSuffix(folderPath, " ") {
+			folderPath += "/"
+		}
+		for _, bfi := range folder.File {
+			fi := bfi.GetFileInfo()
+			res = append(res, &DSFileEntry{
+				Datastore:    dsName,
+				Path:         folderPath + fi.Path,
+				Size:         fi.FileSize,
+				Modification: fi.Modification,
+				Type:         reflect.TypeOf(bfi).Elem().Name(),
+				Owner:        fi.Owner,
+			})
+		}
+	}
+	log.Debugf("BrowseDatastoreFiles: %s, %d files found.", dsPath, len(res))
+	return res, nil
+}
+
+// CollectDatastoreFiles list files on selected datastores, save as CSV for timeline and JSON summary.
+func (vsc *vSphereClient) CollectDatastoreFiles(dsRefs []types.ManagedObjectReference, dsNames []string,
+	pathGlob string, recentSince time.Time) error {
+	allFiles := make([]*DSFileEntry, 0)
+	summary := &DSFileListSummary{
+		PathGlob:       pathGlob,
+		RecentSince:    recentSince,
+		FileCount:      make(map[string]int),
+		ExtensionCount: make(map[string]int),
+		Suspicious:     make([]*DSSuspiciousFile, 0),
+		RecentModified: make([]*DSFileEntry, 0),
+	}
+	for i := range dsRefs {
+		log.Infoln("browsing datastore: ", dsNames[i])
+		files, err := vsc.BrowseDatastoreFiles(dsRefs[i], dsNames[i], pathGlob)
+		if err != nil {
+			log.Errorln("browse datastore ", dsNames[i], ", err: ", err)
+			continue
+		}
+		summary.FileCount[dsNames[i]] = len(files)
+		allFiles = append(allFiles, files...)
+	}
+	for _, f := range allFiles {
+		if f.Type == "FolderFileInfo" {
+			continue
+		}
+		ext := strings.ToLower(path.Ext(f.Path))
+		summary.ExtensionCount[ext]++
+		if reason := dsFileSuspiciousReason(f.Path); reason != "" {
+			summary.Suspicious = append(summary.Suspicious, &DSSuspiciousFile{DSFileEntry: f, Reason: reason})
+		}
+		if f.Modification != nil && f.Modification.After(recentSince) {
+			summary.RecentModified = append(summary.RecentModified, f)
+		}
+	}
+	// timeline should be sorted by modification time
+	sort.SliceStable(allFiles, func(i, j int) bool {
+		if allFiles[i].Modification == nil || allFiles[j].Modification == nil {
+			return allFiles[j].Modification != nil
+		}
+		return allFiles[i].Modification.Before(*allFiles[j].Modification)
+	})
+	log.Infoln("datastore files listed, total: ", len(allFiles), ", suspicious: ", len(summary.Suspicious))
+	err := saveDatastoreFilesCSV(allFiles)
+	if err != nil {
+		log.Errorln("save datastore files csv, err: ", err)
+		return err
+	}
+	fPath, err := SaveJSONOutput("DatastoreFiles_Summary", summary)
+	if err != nil {
+		log.Errorln("save datastore files summary, err: ", err)
+		return err
+	}
+	log.Infoln("datastore files summary stored in json: ", fPath)
+	return nil
+}
+
+// dsFileSuspiciousReason returns non-empty reason if file name looks like ransomware artifacts or dropped payload.
+func dsFileSuspiciousReason(p string) string {
+	baseName := strings.ToLower(path.Base(p))
+	ext := path.Ext(baseName)
+	reason, ok := dsSuspiciousExtensions[ext]
+	if !ok {
+		return ""
+	}
+	// text and html are only flagged if file name looks like ransom note
+	if ext == ".txt" || ext == ".html" {
+		for _, kw := range dsRansomNoteKeywords {
+			if strings.Contains(baseName, kw) {
+				return reason
+			}
+		}
+		return ""
+	}
+	return reason
+}
+
+func saveDatastoreFilesCSV(files []*DSFileEntry) error {
+	wDstFilePath := filepath.Join("output", "DatastoreFiles_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Timestamp", "Datastore", "Path", "Size", "Type", "Owner", "Suspicious"})
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		ts := "-"
+		if f.Modification != nil {
+			ts = strconv.FormatInt(f.Modification.Unix(), 10)
+		}
+		err = cwr.Write([]string{ts, f.Datastore, f.Path, strconv.FormatInt(f.Size, 10), f.Type, f.Owner,
+			dsFileSuspiciousReason(f.Path)})
+		if err != nil {
+			log.Errorln("csv write error:", err)
+			continue
+		}
+	}
+	log.Infoln("datastore files stored in csv: ", wDstFilePath)
+	return nil
+}
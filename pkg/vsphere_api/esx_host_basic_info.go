@@ -2,13 +2,24 @@ package vsphere_api
 
 import (
 	"context"
+	"crypto/x509"
+	"encoding/csv"
+	"encoding/pem"
 	"errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/vmware/govmomi/govc/host/esxcli"
 	"github.com/vmware/govmomi/object"
 	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/methods"
 	"github.com/vmware/govmomi/vim25/mo"
 	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
@@ -17,11 +28,33 @@ var (
 	ErrPropertiesIsNil   = errors.New("properties retrieving finished with nil result")
 )
 
+var (
+	// esxHostPropSet are property paths of HostSystem used by GetInfoFunc1 and GetSecurityInfo
+	esxHostPropSet = []string{"config.product", "config.service", "config.authenticationManagerInfo",
+		"config.ioFilterInfo", "config.certificate", "config.network.dnsConfig", "config.network.vswitch",
+		"config.network.portgroup", "config.network.pnic", "config.network.vnic", "summary.config.sslThumbprint",
+		"configManager.hostAccessManager", "configManager.firewallSystem", "configManager.advancedOption"}
+	// esxHostPropPageSize is max objects returned in one page of property collector result
+	esxHostPropPageSize = int32(100)
+	// esxHostWorkerNum is the number of hosts being collected at the same time
+	esxHostWorkerNum = 8
+	// esxHostCollectTimeout limits time spent on single host
+	esxHostCollectTimeout = 15 * time.Minute
+)
+
+const (
+	esxHostStatusOK      = "ok"
+	esxHostStatusPartial = "partial"
+	esxHostStatusFailed  = "failed"
+)
+
 type ESXHostBasicInfo struct {
 	moref         *object.HostSystem `json:"-"`
 	InventoryPath string             `json:"inventory_path"`
 	inited        bool               `json:"-"`
 	esxcliExec    *esxcli.Executor   `json:"-"`
+	// esxcliResp keeps responses in esxcliRespKept until GetVIBTrustInfo is done, keyed same as esxCLIcmdLst
+	esxcliResp map[string]*esxcli.Response `json:"-"`
 	// esxi service
 	Services []*ESXHostService `json:"services"`
 	// esxi authentication info
@@ -39,6 +72,20 @@ type ESXHostBasicInfo struct {
 	// esxi v-switch list
 	NetVSwitches   []*ESXHostVSW  `json:"net_v_switches"`
 	NetVPortGroups []*ESXHostPGrp `json:"net_v_port_groups"`
+	// esxi lockdown mode, firewall and advanced options
+	Security *ESXHostSecurity `json:"security,omitempty"`
+	// esxi vib acceptance, signature and kernel module ownership
+	VIBTrust *ESXHostVIBTrust `json:"vib_trust,omitempty"`
+}
+
+type esxHostCollectStatus struct {
+	Host string `json:"host"`
+	// Status is one of: ok, partial, failed
+	Status string `json:"status"`
+	// TimedOut is set if host is not finished within esxHostCollectTimeout, reasons tell what was skipped
+	TimedOut   bool     `json:"timed_out,omitempty"`
+	Reasons    []string `json:"reasons,omitempty"`
+	ElapsedSec int64    `json:"elapsed_sec"`
 }
 
 type ESXAuthenticationInfo struct {
@@ -122,43 +169,200 @@ func (vsc *vSphereClient) RetrieveESXiHostBasicInfo(vcbi *VCBasicInfo) error {
 	if len(vcbi.ESXHostObjs) == 0 {
 		return ErrNoObjectInMoList
 	}
+	propCtx, cancel := context.WithTimeout(context.Background(), esxHostCollectTimeout)
+	hostProps, propErr := vsc.retrieveHostProperties(propCtx)
+	cancel()
+	if propErr != nil {
+		log.Errorln("bulk retrieve host properties, err: ", propErr)
+	}
+	hostInfos := make([]*ESXHostBasicInfo, len(vcbi.ESXHostObjs))
+	hostStatus := make([]*esxHostCollectStatus, len(vcbi.ESXHostObjs))
+	jobs := make(chan int)
+	wg := &sync.WaitGroup{}
+	workerNum := esxHostWorkerNum
+	if workerNum > len(vcbi.ESXHostObjs) {
+		workerNum = len(vcbi.ESXHostObjs)
+	}
+	for w := 0; w < workerNum; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				hsys := hostProps[vcbi.ESXHostObjs[i].Reference()]
+				hostInfos[i], hostStatus[i] = collectESXiHostBasicInfo(vcbi.ESXHostObjs[i], vcbi.ESXHostList[i], hsys,
+					propErr)
+			}
+		}()
+	}
 	for i := range vcbi.ESXHostObjs {
-		esxBInfo := &ESXHostBasicInfo{}
-		err := esxBInfo.Init(vcbi.ESXHostObjs[i], vcbi.ESXHostList[i])
-		if err != nil {
-			return err
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	cnt := make(map[string]int)
+	for i := range hostStatus {
+		cnt[hostStatus[i].Status]++
+		// failed host does not have anything worth saving
+		if hostStatus[i].Status != esxHostStatusFailed {
+			vcbi.ESXHosts = append(vcbi.ESXHosts, hostInfos[i])
 		}
-		log.Infoln("retrEsxiHBI, init done.")
-		err = esxBInfo.GetInfoFunc1()
-		if err != nil {
-			log.Errorln("ESXiHostBasicInfoF1, err:", err)
+	}
+	vcbi.ESXHostStatus = hostStatus
+	log.Infof("esxi host collection finished, %d ok, %d partial, %d failed.", cnt[esxHostStatusOK],
+		cnt[esxHostStatusPartial], cnt[esxHostStatusFailed])
+	err := saveESXHostStatusCSV(hostStatus)
+	if err != nil {
+		log.Errorln("save esxi host status csv, err: ", err)
+	}
+	err = saveVIBTrustCSV(vcbi.ESXHosts)
+	if err != nil {
+		log.Errorln("save esxi host vib trust csv, err: ", err)
+	}
+	return nil
+}
+
+// collectESXiHostBasicInfo run every step on single host, failure of one step does not stop the following steps.
+// On timeout, running esxcli command is abandoned, remaining steps are skipped and host is marked as timed out.
+func collectESXiHostBasicInfo(h *object.HostSystem, invtpath string, hsys *mo.HostSystem,
+	propErr error) (*ESXHostBasicInfo, *esxHostCollectStatus) {
+	startTime := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), esxHostCollectTimeout)
+	defer cancel()
+	esxBInfo := &ESXHostBasicInfo{}
+	status := &esxHostCollectStatus{Host: invtpath}
+	stepsOK := 0
+	stepFailed := func(step string, err error) {
+		log.Errorln("retrEsxiHBI-"+step+", host: ", invtpath, ", err: ", err)
+		status.Reasons = append(status.Reasons, step+": "+err.Error())
+	}
+	defer func() {
+		status.ElapsedSec = int64(time.Since(startTime).Seconds())
+		if ctx.Err() == context.DeadlineExceeded {
+			status.TimedOut = true
+			status.Reasons = append(status.Reasons, "timeout: not finished in "+esxHostCollectTimeout.String())
 		}
-		log.Infoln("retrEsxiHBI-GIFunc1, done.")
-		err = esxBInfo.ExposeESXCliv2()
-		if err != nil {
-			return err
+		switch {
+		case len(status.Reasons) == 0:
+			status.Status = esxHostStatusOK
+		case stepsOK == 0:
+			status.Status = esxHostStatusFailed
+		default:
+			status.Status = esxHostStatusPartial
+		}
+		log.Infof("retrEsxiHBI, host %s finished with status %s.", invtpath, status.Status)
+	}()
+	err := esxBInfo.Init(h, invtpath)
+	if err != nil {
+		stepFailed("Init", err)
+		return esxBInfo, status
+	}
+	log.Infoln("retrEsxiHBI, init done, host: ", invtpath)
+	err = esxBInfo.GetInfoFunc1(hsys)
+	switch {
+	case err == nil:
+		stepsOK++
+	case err == ErrPropertiesIsNil:
+		if propErr != nil {
+			err = propErr
 		}
-		log.Infoln("retrEsxiHBI-ExposeESXCli2, done.")
-		err = esxBInfo.GetInfoFunc2()
+		stepFailed("GIFunc1", err)
+	default:
+		// missing property paths only make host partial, the rest is converted
+		stepFailed("GIFunc1", err)
+		stepsOK++
+	}
+	log.Infoln("retrEsxiHBI-GIFunc1, done, host: ", invtpath)
+	err = esxBInfo.GetSecurityInfo(ctx, hsys)
+	if err != nil {
+		if err == ErrPropertiesIsNil && propErr != nil {
+			err = propErr
+		}
+		stepFailed("Security", err)
+	}
+	if esxBInfo.Security != nil {
+		stepsOK++
+	}
+	log.Infoln("retrEsxiHBI-Security, done, host: ", invtpath)
+	err = esxBInfo.ExposeESXCliv2(ctx)
+	if err != nil {
+		stepFailed("ExposeESXCli2", err)
+		return esxBInfo, status
+	}
+	log.Infoln("retrEsxiHBI-ExposeESXCli2, done, host: ", invtpath)
+	okCnt, err := esxBInfo.GetInfoFunc2(ctx)
+	if err != nil {
+		stepFailed("GIFunc2", err)
+	}
+	if okCnt != 0 {
+		stepsOK++
+	}
+	log.Infoln("retrEsxiHBI-GIFunc2, done, host: ", invtpath)
+	err = esxBInfo.GetVIBTrustInfo(ctx)
+	if err != nil {
+		stepFailed("VIBTrust", err)
+	}
+	if esxBInfo.VIBTrust != nil {
+		stepsOK++
+	}
+	log.Infoln("retrEsxiHBI-VIBTrust, done, host: ", invtpath)
+	return esxBInfo, status
+}
+
+func saveESXHostStatusCSV(status []*esxHostCollectStatus) error {
+	wDstFilePath := filepath.Join("output", "ESXHostStatus_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Host", "Status", "ElapsedSeconds", "Reasons"})
+	if err != nil {
+		return err
+	}
+	for _, st := range status {
+		err = cwr.Write([]string{st.Host, st.Status, strconv.FormatInt(st.ElapsedSec, 10),
+			strings.Join(st.Reasons, "AND")})
 		if err != nil {
-			log.Errorln("ESXiHostBasicInfoF2, err:", err)
+			log.Errorln("csv write error:", err)
 		}
-		log.Infoln("retrEsxiHBI-GIFunc2, done.")
-		log.Infoln("retrEsxiHBI, finished.")
 	}
+	log.Infoln("esxi host status stored in csv: ", wDstFilePath)
 	return nil
 }
 
-func (esxhbi *ESXHostBasicInfo) ExposeESXCliv2() (err error) {
-	if esxhbi.inited {
-		esxhbi.esxcliExec, err = esxcli.NewExecutor(GlobalClient.GetSOAPClient(), esxhbi.moref)
-		if err != nil {
-			log.Errorln("initiate esxcli executor failed: ", err)
-			return err
+// ExposeESXCliv2 create esxcli executor of host. Creating executor already takes several SOAP round trips without
+// context, so stop waiting for it once ctx is done, the same way as runESXCli.
+func (esxhbi *ESXHostBasicInfo) ExposeESXCliv2(ctx context.Context) (err error) {
+	if !esxhbi.inited {
+		return ErrPrerequisitesNotSatisfied
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	type newExecResult struct {
+		exec *esxcli.Executor
+		err  error
+	}
+	// buffered, so that abandoned goroutine does not block forever
+	resCh := make(chan newExecResult, 1)
+	go func() {
+		exec, err := esxcli.NewExecutor(GlobalClient.GetSOAPClient(), esxhbi.moref)
+		resCh <- newExecResult{exec: exec, err: err}
+	}()
+	select {
+	case r := <-resCh:
+		if r.err != nil {
+			log.Errorln("initiate esxcli executor failed: ", r.err)
+			return r.err
 		}
+		esxhbi.esxcliExec = r.exec
 		return nil
+	case <-ctx.Done():
+		return ctx.Err()
 	}
-	return ErrPrerequisitesNotSatisfied
 }
 
 func (esxhbi *ESXHostBasicInfo) Init(h *object.HostSystem, invtpath string) error {
@@ -168,54 +372,47 @@ func (esxhbi *ESXHostBasicInfo) Init(h *object.HostSystem, invtpath string) erro
 	return nil
 }
 
-func (esxhbi *ESXHostBasicInfo) GetInfoFunc1() (err error) {
-	// config properties
-	tmpCtx := context.Background()
-	coll := property.DefaultCollector(GlobalClient.GetSOAPClient())
-	filter := new(property.WaitFilter)
-	filter.Add(esxhbi.moref.Reference(), esxhbi.moref.Reference().Type, []string{"config"})
-	req := types.RetrieveProperties{
-		SpecSet: []types.PropertyFilterSpec{filter.Spec},
-	}
-	res, err := coll.RetrieveProperties(tmpCtx, req)
-	if err != nil {
-		log.Errorln("err when retr props. ")
-		return err
-	}
-	ctnt := res.Returnval
-	if len(ctnt) != 1 {
-		log.Errorln("should only have 1 result in array being matched, got ", len(ctnt))
-		return ErrFuzzyResultInList
-	}
-	obj, err := mo.ObjectContentToType(ctnt[0])
-	// here obj should be the same as ref type.
-	if err != nil {
-		log.Errorln("err when obt-content to type.")
-		return err
+// GetInfoFunc1 convert host properties retrieved in bulk by retrieveHostProperties. Property path missing from
+// result is skipped and reported in returned error, the rest is still converted.
+func (esxhbi *ESXHostBasicInfo) GetInfoFunc1(hsys *mo.HostSystem) (err error) {
+	// note: only property paths listed in esxHostPropSet are retrieved, other properties are nil!
+	if hsys == nil || hsys.Config == nil {
+		return ErrPropertiesIsNil
 	}
-	// copy obj and corresponding property
-	// note: you should only retreive specific property as filter specified above, other properties are nil!
-	hsys := obj.(mo.HostSystem)
-	if hsys.Config != nil {
+	missingPaths := make([]string, 0)
+	if hsys.Config.Product.FullName != "" {
 		esxhbi.ProductAbout = productInfoStringer(hsys.Config.Product)
+	} else {
+		missingPaths = append(missingPaths, "config.product")
+	}
+	if hsys.Config.Service != nil {
 		esxhbi.Services = convertServiceInfo2External(hsys.Config.Service)
+	} else {
+		missingPaths = append(missingPaths, "config.service")
+	}
+	if hsys.Config.AuthenticationManagerInfo != nil {
 		esxhbi.AuthInfo = convertAuthStoreInfo2External(hsys.Config.AuthenticationManagerInfo)
-		esxhbi.DNSIPAddrs = hsys.Config.Network.DnsConfig.GetHostDnsConfig().Address
-		esxhbi.VIOFilters = convertIoFilterInfo2External(hsys.Config.IoFilterInfo)
-		// init for zero value
-		esxhbi.CertificateInfo = nil
-		// obtain cert mgr inst
-		certmgr, err := esxhbi.moref.ConfigManager().CertificateManager(tmpCtx)
-		if err != nil {
-			log.Errorln("Certificate Manager of current host cannot be produced by factory, err: ", err)
-		} else {
-			// parse
-			cinfo, err := certmgr.CertificateInfo(tmpCtx)
-			if err != nil {
-				log.Errorln("Err when trying to find cert using mgr: ", err)
-			} else {
-				esxhbi.CertificateInfo = convertHostCertInfo2External(cinfo)
-			}
+	} else {
+		missingPaths = append(missingPaths, "config.authenticationManagerInfo")
+	}
+	esxhbi.VIOFilters = convertIoFilterInfo2External(hsys.Config.IoFilterInfo)
+	// certificate is parsed from host config, so certificate manager of every host is not needed
+	esxhbi.CertificateInfo = nil
+	cinfo, err := parseHostCertificate(hsys.Config.Certificate)
+	if err != nil {
+		if err != ErrNoCertificateFound {
+			log.Errorln("parse host certificate of ", esxhbi.InventoryPath, ", err: ", err)
+		}
+		if hsys.Summary.Config.SslThumbprint != "" {
+			cinfo = &object.HostCertificateInfo{ThumbprintSHA1: hsys.Summary.Config.SslThumbprint}
+		}
+	}
+	if cinfo != nil {
+		esxhbi.CertificateInfo = convertHostCertInfo2External(cinfo)
+	}
+	if hsys.Config.Network != nil {
+		if hsys.Config.Network.DnsConfig != nil {
+			esxhbi.DNSIPAddrs = hsys.Config.Network.DnsConfig.GetHostDnsConfig().Address
 		}
 		// vswitch
 		esxhbi.NetVSwitches = convertHostNetVSW2External(hsys.Config.Network.Vswitch)
@@ -223,11 +420,93 @@ func (esxhbi *ESXHostBasicInfo) GetInfoFunc1() (err error) {
 		// network ifs
 		esxhbi.NetIfs = showNICs(hsys.Config.Network)
 	} else {
-		return ErrPropertiesIsNil
+		missingPaths = append(missingPaths, "config.network")
+	}
+	if len(missingPaths) != 0 {
+		return errors.New("host properties missing: " + strings.Join(missingPaths, ", "))
 	}
 	return nil
 }
 
+// retrieveHostProperties fetch esxHostPropSet of all hosts with a single property collector request over a
+// container view, results are paged by esxHostPropPageSize objects.
+func (vsc *vSphereClient) retrieveHostProperties(ctx context.Context) (map[types.ManagedObjectReference]*mo.HostSystem,
+	error) {
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(ctx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"HostSystem"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(context.Background())
+	}()
+	pc := property.DefaultCollector(vsc.vmwSoapClient)
+	req := types.RetrievePropertiesEx{
+		This: pc.Reference(),
+		SpecSet: []types.PropertyFilterSpec{{
+			ObjectSet: []types.ObjectSpec{{
+				Obj:  ctnrView.Reference(),
+				Skip: types.NewBool(true),
+				SelectSet: []types.BaseSelectionSpec{
+					&types.TraversalSpec{Type: "ContainerView", Path: "view", Skip: types.NewBool(false)},
+				},
+			}},
+			PropSet: []types.PropertySpec{{Type: "HostSystem", PathSet: esxHostPropSet}},
+		}},
+		Options: types.RetrieveOptions{MaxObjects: esxHostPropPageSize},
+	}
+	resp, err := methods.RetrievePropertiesEx(ctx, vsc.vmwSoapClient, &req)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[types.ManagedObjectReference]*mo.HostSystem)
+	pageCnt := 0
+	result := resp.Returnval
+	for result != nil {
+		pageCnt++
+		for _, oc := range result.Objects {
+			hsys := &mo.HostSystem{}
+			err = mo.LoadObjectContent([]types.ObjectContent{oc}, hsys)
+			if err != nil {
+				log.Errorln("load properties of ", oc.Obj.Value, ", err: ", err)
+				continue
+			}
+			for _, mp := range oc.MissingSet {
+				log.Warnln("property ", mp.Path, " of ", oc.Obj.Value, " not retrieved: ", mp.Fault.LocalizedMessage)
+			}
+			res[oc.Obj] = hsys
+		}
+		if result.Token == "" {
+			break
+		}
+		contResp, err := methods.ContinueRetrievePropertiesEx(ctx, vsc.vmwSoapClient,
+			&types.ContinueRetrievePropertiesEx{This: pc.Reference(), Token: result.Token})
+		if err != nil {
+			return res, err
+		}
+		result = &contResp.Returnval
+	}
+	log.Infof("properties of %d hosts retrieved in %d pages.", len(res), pageCnt)
+	return res, nil
+}
+
+// parseHostCertificate parse PEM encoded ssl certificate in host config.
+func parseHostCertificate(pemBytes []byte) (*object.HostCertificateInfo, error) {
+	if len(pemBytes) == 0 {
+		return nil, ErrNoCertificateFound
+	}
+	blk, _ := pem.Decode(pemBytes)
+	if blk == nil {
+		return nil, ErrNoCertificateFound
+	}
+	cert, err := x509.ParseCertificate(blk.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return (&object.HostCertificateInfo{}).FromCertificate(cert), nil
+}
+
 func showNICs(n *types.HostNetworkInfo) []*ESXNetNIC {
 	res := make([]*ESXNetNIC, 0)
 	for _, v := range n.Pnic {
@@ -249,9 +528,8 @@ func showNICs(n *types.HostNetworkInfo) []*ESXNetNIC {
 			MacAddr:    v.Spec.Mac,
 			Type:       v.Device,
 			IsVirtual:  true,
-			IpAddr:     v.Spec.Ip.IpAddress,
-			SubnetMask: v.Spec.Ip.SubnetMask,
-			UsingDHCP:  v.Spec.Ip.Dhcp,
+			IpAddr:     "-",
+			SubnetMask: "-",
 			GatewayIP: func() string {
 				if v.Spec.IpRouteSpec != nil {
 					if v.Spec.IpRouteSpec.IpRouteConfig != nil {
@@ -261,6 +539,9 @@ func showNICs(n *types.HostNetworkInfo) []*ESXNetNIC {
 				return "-"
 			}(),
 		}
+		if v.Spec.Ip != nil {
+			i2.IpAddr, i2.SubnetMask, i2.UsingDHCP = v.Spec.Ip.IpAddress, v.Spec.Ip.SubnetMask, v.Spec.Ip.Dhcp
+		}
 		res = append(res, i2)
 	}
 	return res
@@ -337,30 +618,38 @@ func convertHostCertInfo2External(ci *object.HostCertificateInfo) *ESXHostCert {
 		ThumbprintSHA1:   ci.ThumbprintSHA1, // if managed by VCSA, this will be replaced by VCSA cert
 		ThumbprintSHA256: ci.ThumbprintSHA256,
 		SubjectName: func() string {
-			if ci.SubjectName() == nil {
+			if sn := ci.SubjectName(); sn != nil {
+				return sn.String()
+			}
+			if ci.HostCertificateManagerCertificateInfo.Subject != "" {
 				return ci.HostCertificateManagerCertificateInfo.Subject
 			}
 			return "-"
 		}(),
 		IssuerName: func() string {
-			if ci.IssuerName() == nil {
+			if in := ci.IssuerName(); in != nil {
+				return in.String()
+			}
+			if ci.HostCertificateManagerCertificateInfo.Issuer != "" {
 				return ci.HostCertificateManagerCertificateInfo.Issuer
 			}
 			return "-"
 		}(),
 		NotAfter: func() string {
-			if ci.Certificate == nil {
-				if ci.HostCertificateManagerCertificateInfo.NotAfter != nil {
-					return ci.HostCertificateManagerCertificateInfo.NotAfter.String()
-				}
+			if ci.Certificate != nil {
+				return ci.Certificate.NotAfter.String()
+			}
+			if ci.HostCertificateManagerCertificateInfo.NotAfter != nil {
+				return ci.HostCertificateManagerCertificateInfo.NotAfter.String()
 			}
 			return "-"
 		}(),
 		NotBefore: func() string {
-			if ci.Certificate == nil {
-				if ci.HostCertificateManagerCertificateInfo.NotBefore != nil {
-					return ci.HostCertificateManagerCertificateInfo.NotBefore.String()
-				}
+			if ci.Certificate != nil {
+				return ci.Certificate.NotBefore.String()
+			}
+			if ci.HostCertificateManagerCertificateInfo.NotBefore != nil {
+				return ci.HostCertificateManagerCertificateInfo.NotBefore.String()
 			}
 			return "-"
 		}(),
@@ -407,7 +696,7 @@ func convertAuthStoreInfo2External(aInfo *types.HostAuthenticationManagerInfo) [
 						JoinedDomain:           data.JoinedDomain,
 						TrustedDomain:          data.TrustedDomain,
 						DomainMembershipStatus: data.DomainMembershipStatus,
-						SmartCardAuthEnabled:   *data.SmartCardAuthenticationEnabled,
+						SmartCardAuthEnabled:   data.SmartCardAuthenticationEnabled != nil && *data.SmartCardAuthenticationEnabled,
 					},
 				}
 				extAuthI[i] = authMetd
@@ -436,10 +725,12 @@ func convertServiceInfo2External(srvs *types.HostServiceInfo) []*ESXHostService
 			Required:       t.Required,
 			Running:        t.Running,
 			Policy:         t.Policy,
-			SourcePkgName:  t.SourcePackage.SourcePackageName,
 			FWRuleSetNames: t.Ruleset,
 			Uninstallable:  t.Uninstallable,
 		}
+		if t.SourcePackage != nil {
+			ext_s_S.SourcePkgName = t.SourcePackage.SourcePackageName
+		}
 		extSrv[i] = ext_s_S
 	}
 	return extSrv
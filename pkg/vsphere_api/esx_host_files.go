@@ -0,0 +1,214 @@
+package vsphere_api
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/session"
+	"github.com/vmware/govmomi/vim25/types"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrHostFileNotServed = errors.New("remote path is not served by /host endpoint, only files under /etc are")
+)
+
+var (
+	// ESXHostFileDefaults are persistence locations frequently abused on ESXi
+	ESXHostFileDefaults = []string{"/etc/rc.local.d/local.sh", "/etc/inetd.conf", "/etc/ssh/sshd_config",
+		"/etc/ssh/keys-root/authorized_keys", "/etc/vmware/hostd/config.xml"}
+	// esxHostFileAliases map file to its name under /host endpoint, if it is not simply the path relative to /etc
+	esxHostFileAliases = map[string]string{
+		"/etc/ssh/keys-root/authorized_keys": "ssh_root_authorized_keys",
+	}
+)
+
+type HostFilesManifest struct {
+	StartedAt  time.Time              `json:"started_at"`
+	FinishedAt time.Time              `json:"finished_at"`
+	OutputDir  string                 `json:"output_dir"`
+	Files      []*HostAcquiredFile    `json:"files"`
+	Failed     []*HostFileFailedEntry `json:"failed,omitempty"`
+	Paths      []string               `json:"paths"`
+	Hosts      []string               `json:"hosts"`
+	Server     string                 `json:"server"`
+}
+
+type HostAcquiredFile struct {
+	Host           string    `json:"host"`
+	HostRef        string    `json:"host_ref"`
+	RemotePath     string    `json:"remote_path"`
+	URL            string    `json:"url"`
+	LocalPath      string    `json:"local_path"`
+	DownloadedSize int64     `json:"downloaded_size"`
+	ViaTicket      bool      `json:"via_ticket"`
+	SHA256         string    `json:"sha256"`
+	MD5            string    `json:"md5"`
+	AcquiredAt     time.Time `json:"acquired_at"`
+}
+
+type HostFileFailedEntry struct {
+	Host       string `json:"host"`
+	HostRef    string `json:"host_ref"`
+	RemotePath string `json:"remote_path"`
+	Reason     string `json:"reason"`
+}
+
+// hostFileEndpointName convert absolute file path on ESXi to the name under /host endpoint.
+func hostFileEndpointName(remotePath string) (string, error) {
+	remotePath = path.Clean("/" + strings.TrimPrefix(remotePath, "/"))
+	if alias, ok := esxHostFileAliases[remotePath]; ok {
+		return alias, nil
+	}
+	if !strings.HasPrefix(remotePath, "/etc/") {
+		return "", ErrHostFileNotServed
+	}
+	return strings.TrimPrefix(remotePath, "/etc/"), nil
+}
+
+// CollectHostFiles download files from /host endpoint of each ESXi host, hash while streaming and store under
+// per-host folder named by managed object id, since host names are only unique within datacenter. Standalone ESXi
+// uses current session directly, while vCenter acquires a generic service ticket for every file, since vCenter
+// session is not accepted by hosts.
+func (vsc *vSphereClient) CollectHostFiles(hostElems []list.Element, remotePaths []string) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	manifest := &HostFilesManifest{
+		StartedAt: time.Now(),
+		OutputDir: filepath.Join("output", "HostFiles_"+strconv.FormatInt(time.Now().Unix(), 10)),
+		Files:     make([]*HostAcquiredFile, 0),
+		Failed:    make([]*HostFileFailedEntry, 0),
+		Paths:     remotePaths,
+		Server:    vsc.vmwSoapClient.URL().Host,
+	}
+	tmpCtx := context.Background()
+	for _, hElem := range hostElems {
+		hRef := hElem.Object.Reference().Value
+		manifest.Hosts = append(manifest.Hosts, hElem.Path)
+		hostDir := filepath.Join(manifest.OutputDir, hRef+"_"+safePathComponent(path.Base(hElem.Path)))
+		hostAddr := vsc.vmwSoapClient.URL().Host
+		if vsc.IsVCenter() {
+			var err error
+			hostAddr, err = hostManagementAddr(tmpCtx, object.NewHostSystem(vsc.vmwSoapClient, hElem.Object.Reference()))
+			if err != nil {
+				log.Errorln("management address of host ", hElem.Path, ", err: ", err)
+				for _, p := range remotePaths {
+					manifest.Failed = append(manifest.Failed, &HostFileFailedEntry{Host: hElem.Path, HostRef: hRef,
+						RemotePath: p, Reason: err.Error()})
+				}
+				continue
+			}
+		}
+		for _, p := range remotePaths {
+			acqF, err := vsc.acquireSingleHostFile(tmpCtx, hostAddr, p, hostDir)
+			if err != nil {
+				manifest.Failed = append(manifest.Failed, &HostFileFailedEntry{Host: hElem.Path, HostRef: hRef,
+					RemotePath: p, Reason: err.Error()})
+				log.Errorln("acquire file ", p, " from host ", hElem.Path, ", err: ", err)
+				continue
+			}
+			acqF.Host, acqF.HostRef = hElem.Path, hRef
+			manifest.Files = append(manifest.Files, acqF)
+			log.Infoln("acquired file: ", p, " from host ", hElem.Path, " sha256: ", acqF.SHA256)
+		}
+	}
+	manifest.FinishedAt = time.Now()
+	fPath, err := SaveJSONOutput("HostFiles_Manifest", manifest)
+	if err != nil {
+		log.Errorln("save host files manifest, err: ", err)
+		return err
+	}
+	log.Infoln("host files manifest stored in json: ", fPath)
+	return nil
+}
+
+// hostManagementAddr prefer management IP of host, fallback to its inventory name, same as govmomi does for
+// datastore service ticket. IPv6 address is bracketed to be used as url host.
+func hostManagementAddr(ctx context.Context, host *object.HostSystem) (string, error) {
+	var addr string
+	ips, err := host.ManagementIPs(ctx)
+	if err == nil && len(ips) > 0 {
+		addr = ips[0].String()
+	} else {
+		addr, err = host.ObjectName(ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+	// host added by ipv6 address is named by it as well
+	if ip := net.ParseIP(addr); ip != nil && ip.To4() == nil {
+		return "[" + addr + "]", nil
+	}
+	return addr, nil
+}
+
+// acquireSingleHostFile download single file from /host endpoint of hostAddr to hostDir/remotePath.
+func (vsc *vSphereClient) acquireSingleHostFile(ctx context.Context, hostAddr string, remotePath string,
+	hostDir string) (*HostAcquiredFile, error) {
+	for _, seg := range strings.Split(remotePath, "/") {
+		if seg == ".." {
+			return nil, ErrUnsafeRemotePath
+		}
+	}
+	epName, err := hostFileEndpointName(remotePath)
+	if err != nil {
+		return nil, err
+	}
+	fURL := &url.URL{
+		Scheme: vsc.vmwSoapClient.URL().Scheme,
+		Host:   hostAddr,
+		Path:   "/host/" + epName,
+	}
+	viaTicket := vsc.IsVCenter()
+	req, err := vsc.newDownloadRequest(fURL.String(), !viaTicket)
+	if err != nil {
+		return nil, err
+	}
+	if viaTicket {
+		// ticket is single use and bound to url and method
+		ticket, err := session.NewManager(vsc.vmwSoapClient).AcquireGenericServiceTicket(ctx,
+			&types.SessionManagerHttpServiceRequestSpec{
+				Method: string(types.SessionManagerHttpServiceRequestSpecMethodHttpGet),
+				Url:    fURL.String(),
+			})
+		if err != nil {
+			return nil, err
+		}
+		req.AddCookie(&http.Cookie{Name: "vmware_cgi_ticket", Value: ticket.Id})
+	}
+	localPath := filepath.Join(hostDir, filepath.FromSlash(strings.TrimPrefix(path.Clean(remotePath), "/")))
+	err = os.MkdirAll(filepath.Dir(localPath), 0755)
+	if err != nil {
+		return nil, err
+	}
+	sha256H := sha256.New()
+	md5H := md5.New()
+	n, err := vsc.progressedDownload(req, localPath, sha256H, md5H)
+	if err != nil {
+		return nil, err
+	}
+	return &HostAcquiredFile{
+		RemotePath:     remotePath,
+		URL:            fURL.String(),
+		LocalPath:      localPath,
+		DownloadedSize: n,
+		ViaTicket:      viaTicket,
+		SHA256:         hex.EncodeToString(sha256H.Sum(nil)),
+		MD5:            hex.EncodeToString(md5H.Sum(nil)),
+		AcquiredAt:     time.Now(),
+	}, nil
+}
@@ -0,0 +1,234 @@
+package vsphere_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"strconv"
+	"strings"
+)
+
+var (
+	// esxSecurityOptionKeys are host advanced options related to access control and hardening
+	esxSecurityOptionKeys = []string{"UserVars.ESXiShellTimeOut", "UserVars.ESXiShellInteractiveTimeOut",
+		"UserVars.DcuiTimeOut", "UserVars.SuppressShellWarning", "Security.AccountLockFailures",
+		"Security.AccountUnlockTime", "Security.PasswordQualityControl", "Security.PasswordHistory",
+		"Config.HostAgent.plugins.solo.enableMob", "Config.HostAgent.plugins.hostsvc.esxAdminsGroup",
+		"Config.HostAgent.plugins.hostsvc.esxAdminsGroupAutoAdd", "Config.HostAgent.log.level",
+		"Syslog.global.logHost", "Net.BlockGuestBPDU", "Mem.ShareForceSalting"}
+	// esxSensitiveRulesets are firewall rulesets exposing management or legacy services, should be limited by IP
+	esxSensitiveRulesets = []string{"sshServer", "CIMHttpServer", "CIMHttpsServer", "CIMSLP", "snmp", "webAccess"}
+)
+
+type ESXHostSecurity struct {
+	// LockdownMode is one of: lockdownDisabled, lockdownNormal, lockdownStrict
+	LockdownMode       string           `json:"lockdown_mode,omitempty"`
+	LockdownExceptions []string         `json:"lockdown_exceptions,omitempty"`
+	SystemUsers        []string         `json:"system_users,omitempty"`
+	Firewall           *ESXHostFirewall `json:"firewall,omitempty"`
+	AdvancedOptions    []*ESXHostOption `json:"advanced_options,omitempty"`
+	Errors             []string         `json:"errors,omitempty"`
+	Flags              []string         `json:"flags,omitempty"`
+}
+
+type ESXHostFirewall struct {
+	IncomingBlocked bool                      `json:"incoming_blocked"`
+	OutgoingBlocked bool                      `json:"outgoing_blocked"`
+	Rulesets        []*ESXHostFirewallRuleset `json:"rulesets,omitempty"`
+}
+
+type ESXHostFirewallRuleset struct {
+	Key       string   `json:"key"`
+	Label     string   `json:"label"`
+	Enabled   bool     `json:"enabled"`
+	Required  bool     `json:"required"`
+	Service   string   `json:"service,omitempty"`
+	AllowAll  bool     `json:"allow_all_ip"`
+	AllowedIP []string `json:"allowed_ip,omitempty"`
+	// Rules are formatted as direction/protocol/port[-endPort]
+	Rules []string `json:"rules,omitempty"`
+}
+
+type ESXHostOption struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Type  string `json:"type"`
+}
+
+// GetSecurityInfo collect lockdown mode, firewall and advanced options via host managers referenced by bulk
+// retrieved configManager, independent of esxcli. Failure of one manager does not stop the others.
+func (esxhbi *ESXHostBasicInfo) GetSecurityInfo(ctx context.Context, hsys *mo.HostSystem) error {
+	if hsys == nil || (hsys.ConfigManager.AdvancedOption == nil && hsys.ConfigManager.FirewallSystem == nil &&
+		hsys.ConfigManager.HostAccessManager == nil) {
+		return ErrPropertiesIsNil
+	}
+	c := GlobalClient.GetSOAPClient()
+	res := &ESXHostSecurity{}
+	recordErr := func(step string, err error) {
+		log.Errorln("host security ", step, " of ", esxhbi.InventoryPath, ", err: ", err)
+		res.Errors = append(res.Errors, step+": "+err.Error())
+	}
+	// lockdown
+	if amRef := hsys.ConfigManager.HostAccessManager; amRef != nil {
+		var am mo.HostAccessManager
+		err := property.DefaultCollector(c).RetrieveOne(ctx, *amRef, []string{"lockdownMode"}, &am)
+		if err != nil {
+			recordErr("lockdown mode", err)
+		} else {
+			res.LockdownMode = string(am.LockdownMode)
+		}
+		leResp, err := methods.QueryLockdownExceptions(ctx, c, &types.QueryLockdownExceptions{This: *amRef})
+		if err != nil {
+			recordErr("lockdown exceptions", err)
+		} else {
+			res.LockdownExceptions = leResp.Returnval
+		}
+		suResp, err := methods.QuerySystemUsers(ctx, c, &types.QuerySystemUsers{This: *amRef})
+		if err != nil {
+			recordErr("system users", err)
+		} else {
+			res.SystemUsers = suResp.Returnval
+		}
+	} else {
+		// host access manager exists since 6.0
+		res.Errors = append(res.Errors, "lockdown mode: host access manager not available")
+	}
+	// firewall
+	if fwRef := hsys.ConfigManager.FirewallSystem; fwRef != nil {
+		fwInfo, err := object.NewHostFirewallSystem(c, *fwRef).Info(ctx)
+		if err != nil {
+			recordErr("firewall", err)
+		} else {
+			res.Firewall = convertFirewallInfo2External(fwInfo)
+		}
+	}
+	// advanced options, queried one by one since missing key faults the whole query
+	if optRef := hsys.ConfigManager.AdvancedOption; optRef != nil {
+		optMgr := object.NewOptionManager(c, *optRef)
+		for _, k := range esxSecurityOptionKeys {
+			opts, err := optMgr.Query(ctx, k)
+			if err != nil {
+				// InvalidName means option is not present on this release, anything else is a real failure
+				if soap.IsSoapFault(err) {
+					if _, ok := soap.ToSoapFault(err).VimFault().(types.InvalidName); ok {
+						log.Debugln("host option ", k, " of ", esxhbi.InventoryPath, " not present.")
+						continue
+					}
+				}
+				recordErr("advanced option "+k, err)
+				continue
+			}
+			for i := range opts {
+				ov := opts[i].GetOptionValue()
+				res.AdvancedOptions = append(res.AdvancedOptions, &ESXHostOption{
+					Key:   ov.Key,
+					Value: fmt.Sprintf("%v", ov.Value),
+					Type:  fmt.Sprintf("%T", ov.Value),
+				})
+			}
+		}
+	}
+	res.Flags = hostSecurityFlags(res)
+	for _, f := range res.Flags {
+		log.Warnf("Host %s security flagged: %s", esxhbi.InventoryPath, f)
+	}
+	esxhbi.Security = res
+	if len(res.Errors) != 0 {
+		return errors.New(strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
+
+func convertFirewallInfo2External(fwInfo *types.HostFirewallInfo) *ESXHostFirewall {
+	res := &ESXHostFirewall{
+		IncomingBlocked: fwInfo.DefaultPolicy.IncomingBlocked != nil && *fwInfo.DefaultPolicy.IncomingBlocked,
+		OutgoingBlocked: fwInfo.DefaultPolicy.OutgoingBlocked != nil && *fwInfo.DefaultPolicy.OutgoingBlocked,
+	}
+	for _, rs := range fwInfo.Ruleset {
+		ers := &ESXHostFirewallRuleset{
+			Key:      rs.Key,
+			Label:    rs.Label,
+			Enabled:  rs.Enabled,
+			Required: rs.Required,
+			Service:  rs.Service,
+			AllowAll: true,
+		}
+		if rs.AllowedHosts != nil {
+			ers.AllowAll = rs.AllowedHosts.AllIp
+			ers.AllowedIP = append(ers.AllowedIP, rs.AllowedHosts.IpAddress...)
+			for _, n := range rs.AllowedHosts.IpNetwork {
+				ers.AllowedIP = append(ers.AllowedIP, n.Network+"/"+strconv.Itoa(int(n.PrefixLength)))
+			}
+		}
+		for _, r := range rs.Rule {
+			port := strconv.Itoa(int(r.Port))
+			if r.EndPort != 0 && r.EndPort != r.Port {
+				port += "-" + strconv.Itoa(int(r.EndPort))
+			}
+			ers.Rules = append(ers.Rules, string(r.Direction)+"/"+r.Protocol+"/"+port)
+		}
+		res.Rulesets = append(res.Rulesets, ers)
+	}
+	return res
+}
+
+func hostSecurityFlags(s *ESXHostSecurity) []string {
+	res := make([]string, 0)
+	if s.LockdownMode == string(types.HostLockdownModeLockdownDisabled) {
+		res = append(res, "lockdown mode is disabled")
+	}
+	if len(s.LockdownExceptions) != 0 {
+		res = append(res, "lockdown exception users: "+strings.Join(s.LockdownExceptions, ", "))
+	}
+	if s.Firewall != nil {
+		if !s.Firewall.IncomingBlocked {
+			res = append(res, "firewall default policy allows incoming traffic")
+		}
+		for _, rs := range s.Firewall.Rulesets {
+			if !rs.Enabled || !rs.AllowAll {
+				continue
+			}
+			for _, k := range esxSensitiveRulesets {
+				if rs.Key == k {
+					res = append(res, "firewall ruleset "+rs.Key+" is enabled for all IP addresses")
+					break
+				}
+			}
+		}
+	}
+	for _, o := range s.AdvancedOptions {
+		switch o.Key {
+		case "UserVars.ESXiShellTimeOut", "UserVars.ESXiShellInteractiveTimeOut":
+			if o.Value == "0" {
+				res = append(res, o.Key+" is 0, shell never times out")
+			}
+		case "Security.AccountLockFailures":
+			if o.Value == "0" {
+				res = append(res, "account lockout is disabled")
+			}
+		case "Config.HostAgent.plugins.solo.enableMob":
+			if strings.EqualFold(o.Value, "true") {
+				res = append(res, "managed object browser (MOB) is enabled")
+			}
+		case "Config.HostAgent.plugins.hostsvc.esxAdminsGroupAutoAdd":
+			if strings.EqualFold(o.Value, "true") {
+				res = append(res, "members of AD group in esxAdminsGroup are granted administrator automatically")
+			}
+		case "UserVars.SuppressShellWarning":
+			if o.Value == "1" {
+				res = append(res, "shell enabled warning is suppressed")
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
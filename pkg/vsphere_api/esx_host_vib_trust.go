@@ -0,0 +1,257 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/govc/host/esxcli"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrVIBListUnavailable = errors.New("software vib get result is not available")
+)
+
+var (
+	// esxVIBAcceptanceRank orders acceptance levels from least to most trusted, Community and Partner supported
+	// VIBs are not signed by VMware
+	esxVIBAcceptanceRank = map[string]int{"CommunitySupported": 1, "PartnerSupported": 2, "VMwareAccepted": 3,
+		"VMwareCertified": 4}
+	// esxVIBRecentInstallDays marks VIBs installed within these days
+	esxVIBRecentInstallDays = 30
+)
+
+type ESXHostVIBTrust struct {
+	HostAcceptanceLevel string           `json:"host_acceptance_level,omitempty"`
+	VIBs                []*ESXHostVIB    `json:"vibs,omitempty"`
+	Modules             []*ESXHostModule `json:"modules,omitempty"`
+	Errors              []string         `json:"errors,omitempty"`
+	Flags               []string         `json:"flags,omitempty"`
+}
+
+type ESXHostVIB struct {
+	Name            string `json:"name"`
+	Version         string `json:"version"`
+	Vendor          string `json:"vendor"`
+	AcceptanceLevel string `json:"acceptance_level"`
+	InstallDate     string `json:"install_date,omitempty"`
+	// SignatureVerification is result from software vib signature verify, empty if unknown
+	SignatureVerification string   `json:"signature_verification,omitempty"`
+	Flags                 []string `json:"flags,omitempty"`
+}
+
+type ESXHostModule struct {
+	Name          string   `json:"name"`
+	ModuleFile    string   `json:"module_file,omitempty"`
+	ContainingVIB string   `json:"containing_vib,omitempty"`
+	SignedStatus  string   `json:"signed_status,omitempty"`
+	Flags         []string `json:"flags,omitempty"`
+}
+
+// esxcliFirstValue return first value of key in esxcli result, or empty string
+func esxcliFirstValue(v esxcli.Values, key string) string {
+	if len(v[key]) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(v[key][0])
+}
+
+// GetVIBTrustInfo join results of software vib get, software vib signature verify and system module list that
+// were run by GetInfoFunc2. Each loaded module is further queried by system module get for its containing VIB,
+// which is the only way to tell a module dropped by backdoor VIB installed with --no-sig-check.
+func (esxhbi *ESXHostBasicInfo) GetVIBTrustInfo(ctx context.Context) error {
+	if esxhbi.esxcliExec == nil {
+		return ErrPrerequisitesNotSatisfied
+	}
+	// responses are not needed afterwards, while host info is kept until all hosts are collected
+	defer func() {
+		esxhbi.esxcliResp = nil
+	}()
+	vibResp, ok := esxhbi.esxcliResp["SoftVIBs"]
+	if !ok {
+		return ErrVIBListUnavailable
+	}
+	res := &ESXHostVIBTrust{}
+	recordErr := func(step string, err error) {
+		log.Errorln("host vib trust ", step, " of ", esxhbi.InventoryPath, ", err: ", err)
+		res.Errors = append(res.Errors, step+": "+err.Error())
+	}
+	acceptResp, err := esxhbi.runESXCli(ctx, []string{"software", "acceptance", "get"})
+	if err != nil {
+		recordErr("host acceptance level", err)
+	} else {
+		res.HostAcceptanceLevel = strings.TrimSpace(acceptResp.String)
+	}
+	// signature verification, keyed by vib name
+	sigResult := make(map[string]string)
+	sigResp, sigOK := esxhbi.esxcliResp["SoftVIBVerify"]
+	if sigOK {
+		for _, v := range sigResp.Values {
+			sigResult[esxcliFirstValue(v, "Name")] = esxcliFirstValue(v, "SignatureVerification")
+		}
+	} else {
+		res.Errors = append(res.Errors, "vib signature: software vib signature verify result is not available")
+	}
+	// signedVIBs are VIBs passing signature verification, all VIBs are treated as signed if verification is unknown
+	signedVIBs := make(map[string]bool)
+	for _, v := range vibResp.Values {
+		vib := &ESXHostVIB{
+			Name:                  esxcliFirstValue(v, "Name"),
+			Version:               esxcliFirstValue(v, "Version"),
+			Vendor:                esxcliFirstValue(v, "Vendor"),
+			AcceptanceLevel:       esxcliFirstValue(v, "AcceptanceLevel"),
+			InstallDate:           esxcliFirstValue(v, "InstallDate"),
+			SignatureVerification: sigResult[esxcliFirstValue(v, "Name")],
+		}
+		vib.Flags = vibTrustFlags(vib, res.HostAcceptanceLevel, sigOK)
+		signedVIBs[vib.Name] = !sigOK || strings.HasPrefix(vib.SignatureVerification, "Succeeded")
+		res.VIBs = append(res.VIBs, vib)
+	}
+	// kernel modules, only loaded ones are checked
+	if modResp, ok := esxhbi.esxcliResp["SysMod"]; ok {
+		for _, v := range modResp.Values {
+			if !strings.EqualFold(esxcliFirstValue(v, "IsLoaded"), "true") {
+				continue
+			}
+			if ctx.Err() != nil {
+				recordErr("kernel modules", ctx.Err())
+				break
+			}
+			mod := &ESXHostModule{Name: esxcliFirstValue(v, "Name")}
+			modGetResp, err := esxhbi.runESXCli(ctx, []string{"system", "module", "get", "--module=" + mod.Name})
+			if err != nil {
+				recordErr("kernel module "+mod.Name, err)
+			} else if len(modGetResp.Values) != 0 {
+				mod.ModuleFile = esxcliFirstValue(modGetResp.Values[0], "ModuleFile")
+				mod.ContainingVIB = esxcliFirstValue(modGetResp.Values[0], "ContainingVIB")
+				mod.SignedStatus = esxcliFirstValue(modGetResp.Values[0], "SignedStatus")
+				mod.Flags = moduleTrustFlags(mod, signedVIBs)
+			}
+			res.Modules = append(res.Modules, mod)
+		}
+	} else {
+		res.Errors = append(res.Errors, "kernel modules: system module list result is not available")
+	}
+	if res.HostAcceptanceLevel == "CommunitySupported" {
+		res.Flags = append(res.Flags, "host acceptance level is CommunitySupported, unsigned VIBs can be installed")
+	}
+	for _, vib := range res.VIBs {
+		for _, f := range vib.Flags {
+			res.Flags = append(res.Flags, "vib "+vib.Name+": "+f)
+		}
+	}
+	for _, mod := range res.Modules {
+		for _, f := range mod.Flags {
+			res.Flags = append(res.Flags, "kernel module "+mod.Name+": "+f)
+		}
+	}
+	for _, f := range res.Flags {
+		log.Warnf("Host %s vib trust flagged: %s", esxhbi.InventoryPath, f)
+	}
+	esxhbi.VIBTrust = res
+	if len(res.Errors) != 0 {
+		return errors.New(strings.Join(res.Errors, "; "))
+	}
+	return nil
+}
+
+func vibTrustFlags(vib *ESXHostVIB, hostLevel string, sigKnown bool) []string {
+	res := make([]string, 0)
+	vibRank, ok := esxVIBAcceptanceRank[vib.AcceptanceLevel]
+	switch {
+	case !ok:
+		res = append(res, "unknown acceptance level "+vib.AcceptanceLevel)
+	case vibRank <= esxVIBAcceptanceRank["PartnerSupported"]:
+		res = append(res, "acceptance level is "+vib.AcceptanceLevel)
+	}
+	// esxcli refuses vib less trusted than host level, unless --force or --no-sig-check is used
+	if hostRank, hOK := esxVIBAcceptanceRank[hostLevel]; ok && hOK && vibRank < hostRank {
+		res = append(res, "acceptance level is lower than host level "+hostLevel+
+			", possibly installed with --no-sig-check or --force")
+	}
+	if sigKnown && !strings.HasPrefix(vib.SignatureVerification, "Succeeded") {
+		if vib.SignatureVerification == "" {
+			res = append(res, "missing from signature verification result")
+		} else {
+			res = append(res, "signature verification: "+vib.SignatureVerification)
+		}
+	}
+	if instDate, err := time.Parse("2006-01-02", vib.InstallDate); err == nil &&
+		time.Since(instDate) < time.Duration(esxVIBRecentInstallDays)*24*time.Hour {
+		res = append(res, "installed in last "+strconv.Itoa(esxVIBRecentInstallDays)+" days")
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func moduleTrustFlags(mod *ESXHostModule, signedVIBs map[string]bool) []string {
+	res := make([]string, 0)
+	signed, known := signedVIBs[mod.ContainingVIB]
+	switch {
+	case mod.ContainingVIB == "":
+		res = append(res, "not owned by any vib")
+	case !known:
+		res = append(res, "containing vib "+mod.ContainingVIB+" is not installed")
+	case !signed:
+		res = append(res, "containing vib "+mod.ContainingVIB+" is not signed")
+	}
+	if strings.EqualFold(mod.SignedStatus, "Unsigned") {
+		res = append(res, "module is unsigned")
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// saveVIBTrustCSV write flagged VIBs and kernel modules of all hosts into single csv
+func saveVIBTrustCSV(hosts []*ESXHostBasicInfo) error {
+	wDstFilePath := filepath.Join("output", "ESXHostVIBTrust_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Host", "Type", "Name", "Version", "Vendor", "AcceptanceLevel", "InstallDate",
+		"Signature", "ContainingVIB", "Flags"})
+	if err != nil {
+		return err
+	}
+	for _, h := range hosts {
+		if h.VIBTrust == nil {
+			continue
+		}
+		for _, vib := range h.VIBTrust.VIBs {
+			if len(vib.Flags) == 0 {
+				continue
+			}
+			err = cwr.Write([]string{h.InventoryPath, "vib", vib.Name, vib.Version, vib.Vendor, vib.AcceptanceLevel,
+				vib.InstallDate, vib.SignatureVerification, "", strings.Join(vib.Flags, "AND")})
+			if err != nil {
+				log.Errorln("csv write error:", err)
+			}
+		}
+		for _, mod := range h.VIBTrust.Modules {
+			if len(mod.Flags) == 0 {
+				continue
+			}
+			err = cwr.Write([]string{h.InventoryPath, "module", mod.Name, "", "", "", "", mod.SignedStatus,
+				mod.ContainingVIB, strings.Join(mod.Flags, "AND")})
+			if err != nil {
+				log.Errorln("csv write error:", err)
+			}
+		}
+	}
+	log.Infoln("esxi host vib trust stored in csv: ", wDstFilePath)
+	return nil
+}
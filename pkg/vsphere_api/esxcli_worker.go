@@ -1,8 +1,10 @@
 package vsphere_api
 
 import (
+	"context"
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	log "github.com/sirupsen/logrus"
 	"github.com/vmware/govmomi/govc/host/esxcli"
 	"os"
@@ -39,12 +41,16 @@ var (
 		"NetVMList":       "network vm list",
 		"NetIPARPCache":   "network ip neighbor list",
 	}
+	// esxcliRespKept are responses analyzed by GetVIBTrustInfo, others are only saved to file
+	esxcliRespKept = map[string]bool{"SoftVIBs": true, "SoftVIBVerify": true, "SysMod": true}
 )
 
-func (esxhbi *ESXHostBasicInfo) GetInfoFunc2() (err error) {
+// GetInfoFunc2 run esxcli command list and save results, returns count of succeeded commands, and error describing
+// failed ones. Running command is abandoned and remaining commands are skipped once ctx is done.
+func (esxhbi *ESXHostBasicInfo) GetInfoFunc2(ctx context.Context) (okCnt int, err error) {
 	// esxcli must be run using real esxi instance, the simulator does NOT implement necessary method
 	if esxhbi.esxcliExec == nil {
-		return ErrPrerequisitesNotSatisfied
+		return 0, ErrPrerequisitesNotSatisfied
 	}
 	// execute command list, command are recorded in docs
 	// save as csv file, filename: machineName-CommandName-Timestamp.csv
@@ -53,26 +59,71 @@ func (esxhbi *ESXHostBasicInfo) GetInfoFunc2() (err error) {
 	if len(machineName) == 0 {
 		machineName, err = GetNanoID(6)
 		if err != nil {
-			return err
+			return 0, err
 		}
 	}
 	log.Infoln("esxcli worker, machine name: ", machineName)
+	esxhbi.esxcliResp = make(map[string]*esxcli.Response)
+	failedCmds := make([]string, 0)
 	for k, v := range esxCLIcmdLst {
+		if ctx.Err() != nil {
+			failedCmds = append(failedCmds, k+" ("+ctx.Err().Error()+")")
+			continue
+		}
 		log.Infoln("esxcli worker, currently running: ", k)
-		resp, err := esxhbi.esxcliExec.Run(strings.Split(v, " "))
+		resp, err := esxhbi.runESXCli(ctx, strings.Split(v, " "))
 		if err != nil {
 			log.Errorln("ESXCLI Exec -", k, ", Err: ", err)
+			if ctx.Err() != nil {
+				failedCmds = append(failedCmds, k+" ("+ctx.Err().Error()+")")
+			} else {
+				failedCmds = append(failedCmds, k)
+			}
 			continue
 		}
 		log.Debugln("esxcli worker,", k, " finishing running.")
+		if esxcliRespKept[k] {
+			esxhbi.esxcliResp[k] = resp
+		}
 		err = FormatAndSave(machineName, k, resp)
 		if err != nil {
 			log.Errorln("ESXCLI Format and Save -", k, " Err:", err)
+			failedCmds = append(failedCmds, k)
 			continue
 		}
+		okCnt++
 		log.Debugln("esxcli worker,", k, " resp formatted and saved.")
 	}
-	return nil
+	if len(failedCmds) != 0 {
+		sort.Strings(failedCmds)
+		return okCnt, errors.New(strconv.Itoa(len(failedCmds)) + " of " + strconv.Itoa(len(esxCLIcmdLst)) +
+			" esxcli commands failed: " + strings.Join(failedCmds, ", "))
+	}
+	return okCnt, nil
+}
+
+// runESXCli run single esxcli command and stop waiting for it once ctx is done, since esxcli executor does not
+// accept context. Abandoned command may still finish on host, its result is discarded.
+func (esxhbi *ESXHostBasicInfo) runESXCli(ctx context.Context, args []string) (*esxcli.Response, error) {
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	type runResult struct {
+		resp *esxcli.Response
+		err  error
+	}
+	// buffered, so that abandoned goroutine does not block forever
+	resCh := make(chan runResult, 1)
+	go func() {
+		resp, err := esxhbi.esxcliExec.Run(args)
+		resCh <- runResult{resp: resp, err: err}
+	}()
+	select {
+	case r := <-resCh:
+		return r.resp, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 func FormatAndSave(machineName string, cateName string, resp *esxcli.Response) (err error) {
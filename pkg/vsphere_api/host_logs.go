@@ -0,0 +1,269 @@
+package vsphere_api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"io"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidResumeManifest = errors.New("resume manifest is invalid or not readable")
+)
+
+var (
+	// HostLogKeyDefaults are logs needed for most investigation, matched against log key or file name
+	HostLogKeyDefaults = []string{"hostd", "vpxa", "auth", "shell", "vmkernel", "vpxd"}
+	// hostLogPageLines is lines per BrowseDiagnosticLog call, vCenter allows 500 at most, ESXi allows 1000
+	hostLogPageLines = int32(500)
+)
+
+type HostLogsManifest struct {
+	StartedAt  time.Time    `json:"started_at"`
+	FinishedAt time.Time    `json:"finished_at"`
+	OutputDir  string       `json:"output_dir"`
+	LogKeys    []string     `json:"log_keys"`
+	ResumedOf  string       `json:"resumed_of,omitempty"`
+	Logs       []*HostLog   `json:"logs"`
+	Targets    []*LogTarget `json:"targets"`
+	Server     string       `json:"server"`
+}
+
+// LogTarget is a host or vCenter itself, with all log descriptors it offers.
+type LogTarget struct {
+	Name string `json:"name"`
+	// Ref is managed object id of host, or instance uuid of vCenter, logs are resumed by it
+	Ref         string                                 `json:"ref"`
+	IsVCenter   bool                                   `json:"is_vcenter"`
+	Descriptors []types.DiagnosticManagerLogDescriptor `json:"descriptors,omitempty"`
+	Missing     []string                               `json:"missing,omitempty"`
+	Error       string                                 `json:"error,omitempty"`
+}
+
+type HostLog struct {
+	Target    string `json:"target"`
+	TargetRef string `json:"target_ref"`
+	Key       string `json:"key"`
+	FileName  string `json:"file_name"`
+	LocalPath string `json:"local_path,omitempty"`
+	// StartLine is where this run started, NextLine is where next run should start to resume
+	StartLine int32 `json:"start_line"`
+	NextLine  int32 `json:"next_line"`
+	LineEnd   int32 `json:"line_end"`
+	// LastLineSHA256 is hash of line NextLine-1, to tell whether log is still the same one on resumption
+	LastLineSHA256 string `json:"last_line_sha256,omitempty"`
+	Rotated        bool   `json:"rotated,omitempty"`
+	SHA256         string `json:"sha256,omitempty"`
+	Error          string `json:"error,omitempty"`
+}
+
+// hostLogMatches check if descriptor is selected by key, which is either the log key itself, vCenter key prefix
+// like "vpxd" of "vpxd:vpxd-1.log", or file name without extension like "auth" of "/var/log/auth.log".
+func hostLogMatches(desc types.DiagnosticManagerLogDescriptor, key string) bool {
+	if desc.Key == key || strings.HasPrefix(desc.Key, key+":") {
+		return true
+	}
+	fName := path.Base(desc.FileName)
+	return strings.TrimSuffix(fName, path.Ext(fName)) == key
+}
+
+// loadHostLogOffsets read every log from previous manifest, keyed by target ref and log key.
+func loadHostLogOffsets(manifestPath string) (map[string]*HostLog, error) {
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		log.Errorln("read resume manifest, err: ", err)
+		return nil, ErrInvalidResumeManifest
+	}
+	prev := &HostLogsManifest{}
+	err = json.Unmarshal(data, prev)
+	if err != nil {
+		log.Errorln("parse resume manifest, err: ", err)
+		return nil, ErrInvalidResumeManifest
+	}
+	res := make(map[string]*HostLog)
+	// NextLine is valid even if log failed in the middle
+	for _, l := range prev.Logs {
+		res[l.TargetRef+"|"+l.Key] = l
+	}
+	return res, nil
+}
+
+func hostLogLineFingerprint(line string) string {
+	sum := sha256.Sum256([]byte(line))
+	return hex.EncodeToString(sum[:])
+}
+
+// CollectHostLogs list log descriptors of vCenter (if connected) and each host via QueryDescriptions, then page
+// through BrowseDiagnosticLog to download selected logs. If resumeManifest is given, each log continues from
+// line recorded in it, instead of from the beginning, as long as the last downloaded line is still the same.
+func (vsc *vSphereClient) CollectHostLogs(hostElems []list.Element, logKeys []string, resumeManifest string) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	offsets := make(map[string]*HostLog)
+	if resumeManifest != "" {
+		var err error
+		offsets, err = loadHostLogOffsets(resumeManifest)
+		if err != nil {
+			return err
+		}
+	}
+	manifest := &HostLogsManifest{
+		StartedAt: time.Now(),
+		OutputDir: filepath.Join("output", "HostLogs_"+strconv.FormatInt(time.Now().Unix(), 10)),
+		LogKeys:   logKeys,
+		ResumedOf: resumeManifest,
+		Logs:      make([]*HostLog, 0),
+		Targets:   make([]*LogTarget, 0),
+		Server:    vsc.vmwSoapClient.URL().Host,
+	}
+	tmpCtx := context.Background()
+	// nil host means the connected server itself, which is vCenter or standalone ESXi
+	hosts := []*object.HostSystem{nil}
+	// url hostname differs by how server is connected, host on standalone ESXi is always ha-host
+	selfRef := "ha-host"
+	if vsc.IsVCenter() {
+		selfRef = "vcenter-" + vsc.vmwSoapClient.ServiceContent.About.InstanceUuid
+	}
+	targets := []*LogTarget{{Name: vsc.vmwSoapClient.URL().Hostname(), Ref: selfRef, IsVCenter: vsc.IsVCenter()}}
+	if vsc.IsVCenter() {
+		for _, hElem := range hostElems {
+			hosts = append(hosts, object.NewHostSystem(vsc.vmwSoapClient, hElem.Object.Reference()))
+			targets = append(targets, &LogTarget{Name: hElem.Path, Ref: hElem.Object.Reference().Value})
+		}
+	}
+	for i, h := range hosts {
+		tgt := targets[i]
+		manifest.Targets = append(manifest.Targets, tgt)
+		descs, err := vsc.vmwDiagMgr.QueryDescriptions(tmpCtx, h)
+		if err != nil {
+			log.Errorln("query log descriptions of ", tgt.Name, ", err: ", err)
+			tgt.Error = err.Error()
+			continue
+		}
+		tgt.Descriptors = descs
+		for _, k := range logKeys {
+			matched := false
+			for _, desc := range descs {
+				if !hostLogMatches(desc, k) {
+					continue
+				}
+				matched = true
+				manifest.Logs = append(manifest.Logs, vsc.browseHostLog(tmpCtx, h, tgt, desc,
+					offsets[tgt.Ref+"|"+desc.Key], manifest.OutputDir))
+			}
+			if !matched {
+				tgt.Missing = append(tgt.Missing, k)
+			}
+		}
+	}
+	manifest.FinishedAt = time.Now()
+	fPath, err := SaveJSONOutput("HostLogs_Manifest", manifest)
+	if err != nil {
+		log.Errorln("save host logs manifest, err: ", err)
+		return err
+	}
+	log.Infoln("host logs manifest stored in json, use it to resume next time: ", fPath)
+	return nil
+}
+
+// browseHostLog page through single log from where prev stopped (nil to start from beginning) to current end, lines
+// are written as they are received, so NextLine is still valid for resumption if it fails in the middle. Log is
+// treated as rotated if it is shorter than NextLine of prev, or line NextLine-1 is no longer the one downloaded.
+func (vsc *vSphereClient) browseHostLog(ctx context.Context, h *object.HostSystem, tgt *LogTarget,
+	desc types.DiagnosticManagerLogDescriptor, prev *HostLog, outDir string) *HostLog {
+	hl := &HostLog{
+		Target:    tgt.Name,
+		TargetRef: tgt.Ref,
+		Key:       desc.Key,
+		FileName:  desc.FileName,
+	}
+	if prev != nil {
+		hl.StartLine, hl.NextLine, hl.LastLineSHA256 = prev.NextLine, prev.NextLine, prev.LastLineSHA256
+	}
+	recordErr := func(err error) *HostLog {
+		log.Errorln("browse log ", desc.Key, " of ", tgt.Name, ", err: ", err)
+		hl.Error = err.Error()
+		return hl
+	}
+	// current end of log, to detect rotation since last run
+	header, err := vsc.vmwDiagMgr.BrowseLog(ctx, h, desc.Key, math.MaxInt32, 0)
+	if err != nil {
+		return recordErr(err)
+	}
+	hl.LineEnd = header.LineEnd
+	rotatedReason := ""
+	switch {
+	case hl.StartLine > header.LineEnd:
+		rotatedReason = "is shorter than recorded offset"
+	case hl.StartLine > 0 && hl.LastLineSHA256 != "":
+		lastPage, err := vsc.vmwDiagMgr.BrowseLog(ctx, h, desc.Key, hl.StartLine-1, 1)
+		if err != nil {
+			return recordErr(err)
+		}
+		if len(lastPage.LineText) == 0 || hostLogLineFingerprint(lastPage.LineText[0]) != hl.LastLineSHA256 {
+			rotatedReason = "does not have the last downloaded line at recorded offset"
+		}
+	}
+	if rotatedReason != "" {
+		log.Warnln("log ", desc.Key, " of ", tgt.Name, " ", rotatedReason, ", rotated, start over.")
+		hl.Rotated = true
+		hl.StartLine = 0
+		hl.NextLine = 0
+		hl.LastLineSHA256 = ""
+	}
+	// key of vCenter log contains ":"
+	fName := strings.NewReplacer(":", "_", "/", "_").Replace(desc.Key) + "_from_" +
+		strconv.Itoa(int(hl.StartLine)) + ".log"
+	hl.LocalPath = filepath.Join(outDir, tgt.Ref+"_"+safePathComponent(path.Base(tgt.Name)), fName)
+	err = os.MkdirAll(filepath.Dir(hl.LocalPath), 0755)
+	if err != nil {
+		return recordErr(err)
+	}
+	fd, err := os.Create(hl.LocalPath)
+	if err != nil {
+		return recordErr(err)
+	}
+	defer fd.Close()
+	defer fd.Sync()
+	sha256H := sha256.New()
+	w := io.MultiWriter(fd, sha256H)
+	for hl.NextLine < hl.LineEnd {
+		page, err := vsc.vmwDiagMgr.BrowseLog(ctx, h, desc.Key, hl.NextLine, hostLogPageLines)
+		if err != nil {
+			return recordErr(err)
+		}
+		for _, line := range page.LineText {
+			_, err = fmt.Fprintln(w, line)
+			if err != nil {
+				return recordErr(err)
+			}
+		}
+		hl.NextLine += int32(len(page.LineText))
+		if len(page.LineText) != 0 {
+			hl.LastLineSHA256 = hostLogLineFingerprint(page.LineText[len(page.LineText)-1])
+		}
+		// log keeps growing while paging
+		hl.LineEnd = page.LineEnd
+		if len(page.LineText) == 0 {
+			break
+		}
+	}
+	hl.SHA256 = hex.EncodeToString(sha256H.Sum(nil))
+	log.Infof("log %s of %s downloaded, line %d to %d.", desc.Key, tgt.Name, hl.StartLine, hl.NextLine)
+	return hl
+}
@@ -123,3 +123,97 @@ func (vsc *vSphereClient) ListDataCenter() error {
 	vsc.SetCtxData("dcList", dcLst)
 	return nil
 }
+
+func (vsc *vSphereClient) ListDatastores() error {
+	tmpctx := context.Background()
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	objKind := []string{"Datastore"}
+	objRootDir := vsc.vmwSoapClient.ServiceContent.RootFolder
+	ctnrView, err := viewMgr.CreateContainerView(tmpctx, objRootDir, objKind, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpctx)
+	}()
+	filterSpec := property.Filter{"name": "*"}
+	dsLstV, err := ctnrView.Find(tmpctx, objKind, filterSpec)
+	if err != nil {
+		return err
+	}
+	if len(dsLstV) == 0 {
+		log.Warn("Retrieved data length of Datastore List is zero.")
+	}
+	log.Debugf("Retrieved Datastore: %v", dsLstV)
+	// same as datacenter, save as list.Element only.
+	dsLst := make([]list.Element, 0)
+	tmpFinder := find.NewFinder(vsc.vmwSoapClient, true)
+	for _, oMRO := range dsLstV {
+		elem, err := tmpFinder.Element(tmpctx, oMRO)
+		if err != nil {
+			if soap.IsSoapFault(err) {
+				_, ok := soap.ToSoapFault(err).VimFault().(types.ManagedObjectNotFound)
+				if ok {
+					// object was deleted after v.Find()
+					continue
+				}
+			}
+			log.Errorln("inlinefunc, convert-to-elem, err: ", err)
+			continue
+		}
+		dsLst = append(dsLst, *elem)
+	}
+	// dsLst, type=([]list.Element)
+	vsc.SetCtxData("dsList", dsLst)
+	return nil
+}
+
+func (vsc *vSphereClient) ListVirtualMachines() error {
+	tmpctx := context.Background()
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	objKind := []string{"VirtualMachine"}
+	objRootDir := vsc.vmwSoapClient.ServiceContent.RootFolder
+	ctnrView, err := viewMgr.CreateContainerView(tmpctx, objRootDir, objKind, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpctx)
+	}()
+	filterSpec := property.Filter{"name": "*"}
+	vmLstV, err := ctnrView.Find(tmpctx, objKind, filterSpec)
+	if err != nil {
+		return err
+	}
+	if len(vmLstV) == 0 {
+		log.Warn("Retrieved data length of Virtual Machine List is zero.")
+	}
+	log.Debugf("Retrieved Virtual Machine: %v", vmLstV)
+	// same as datacenter, save as list.Element only.
+	vmLst := make([]list.Element, 0)
+	tmpFinder := find.NewFinder(vsc.vmwSoapClient, true)
+	for _, oMRO := range vmLstV {
+		elem, err := tmpFinder.Element(tmpctx, oMRO)
+		if err != nil {
+			if soap.IsSoapFault(err) {
+				_, ok := soap.ToSoapFault(err).VimFault().(types.ManagedObjectNotFound)
+				if ok {
+					// object was deleted after v.Find()
+					continue
+				}
+			}
+			log.Errorln("inlinefunc, convert-to-elem, err: ", err)
+			continue
+		}
+		vmLst = append(vmLst, *elem)
+	}
+	// vmLst, type=([]list.Element)
+	vsc.SetCtxData("vmList", vmLst)
+	return nil
+}
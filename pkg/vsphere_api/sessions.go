@@ -0,0 +1,168 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// sessionToolKeywords matches lower-case user agent of scripting and automation tools, which are not used by
+	// daily administration via vSphere Client
+	sessionToolKeywords = []string{"govc", "govmomi", "pyvmomi", "python", "powercli", "curl", "wget",
+		"go-http-client", "rbvmomi", "ruby", "perl", "postman", "axios", "okhttp", "libwww"}
+)
+
+type SessionReport struct {
+	CollectedAt      time.Time    `json:"collected_at"`
+	Server           string       `json:"server"`
+	ExpectedNetworks []string     `json:"expected_networks,omitempty"`
+	Sessions         []*vcSession `json:"sessions"`
+}
+
+type vcSession struct {
+	Key              string    `json:"key"`
+	UserName         string    `json:"user_name"`
+	FullName         string    `json:"full_name,omitempty"`
+	LoginTime        time.Time `json:"login_time"`
+	LastActiveTime   time.Time `json:"last_active_time"`
+	IpAddress        string    `json:"ip_address,omitempty"`
+	UserAgent        string    `json:"user_agent,omitempty"`
+	Locale           string    `json:"locale,omitempty"`
+	MessageLocale    string    `json:"message_locale,omitempty"`
+	CallCount        int64     `json:"call_count"`
+	ExtensionSession bool      `json:"extension_session"`
+	IsCurrentSession bool      `json:"is_current_session"`
+	Flags            []string  `json:"flags,omitempty"`
+}
+
+// CollectSessions read SessionManager.sessionList, mark session of this program, flag session from IP not in
+// expectedNets or using scripting tools. Empty expectedNets skips IP check.
+func (vsc *vSphereClient) CollectSessions(expectedNets []*net.IPNet) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	var sessMgr mo.SessionManager
+	err := property.DefaultCollector(vsc.vmwSoapClient).RetrieveOne(tmpCtx,
+		*vsc.vmwSoapClient.ServiceContent.SessionManager, []string{"sessionList", "currentSession"}, &sessMgr)
+	if err != nil {
+		return err
+	}
+	curKey := ""
+	if sessMgr.CurrentSession != nil {
+		curKey = sessMgr.CurrentSession.Key
+	}
+	report := &SessionReport{
+		CollectedAt: time.Now(),
+		Server:      vsc.vmwSoapClient.URL().Host,
+		Sessions:    make([]*vcSession, 0, len(sessMgr.SessionList)),
+	}
+	for _, n := range expectedNets {
+		report.ExpectedNetworks = append(report.ExpectedNetworks, n.String())
+	}
+	flaggedCnt := 0
+	for _, us := range sessMgr.SessionList {
+		s := &vcSession{
+			Key:              us.Key,
+			UserName:         us.UserName,
+			FullName:         us.FullName,
+			LoginTime:        us.LoginTime,
+			LastActiveTime:   us.LastActiveTime,
+			IpAddress:        us.IpAddress,
+			UserAgent:        us.UserAgent,
+			Locale:           us.Locale,
+			MessageLocale:    us.MessageLocale,
+			CallCount:        us.CallCount,
+			ExtensionSession: us.ExtensionSession != nil && *us.ExtensionSession,
+			IsCurrentSession: us.Key == curKey,
+		}
+		// our own session is marked only, never flagged
+		if !s.IsCurrentSession {
+			s.Flags = sessionFlags(s, expectedNets)
+		}
+		if len(s.Flags) != 0 {
+			flaggedCnt++
+			log.Warnf("Session flagged: %s from %s (%s), %s", s.UserName, s.IpAddress, s.UserAgent,
+				strings.Join(s.Flags, "; "))
+		}
+		report.Sessions = append(report.Sessions, s)
+	}
+	sort.SliceStable(report.Sessions, func(i, j int) bool {
+		return report.Sessions[i].LoginTime.Before(report.Sessions[j].LoginTime)
+	})
+	log.Infof("%d sessions retrieved, %d flagged.", len(report.Sessions), flaggedCnt)
+	fPath, err := SaveJSONOutput("Sessions", report)
+	if err != nil {
+		log.Errorln("save sessions json, err: ", err)
+		return err
+	}
+	log.Infoln("sessions stored in json: ", fPath)
+	return saveSessionsCSV(report.Sessions)
+}
+
+func sessionFlags(s *vcSession, expectedNets []*net.IPNet) []string {
+	res := make([]string, 0)
+	if len(expectedNets) != 0 && s.IpAddress != "" {
+		ip := net.ParseIP(s.IpAddress)
+		expected := ip != nil && ip.IsLoopback()
+		for _, n := range expectedNets {
+			if ip != nil && n.Contains(ip) {
+				expected = true
+				break
+			}
+		}
+		if !expected {
+			res = append(res, "source ip not in expected networks")
+		}
+	}
+	ua := strings.ToLower(s.UserAgent)
+	for _, kw := range sessionToolKeywords {
+		if strings.Contains(ua, kw) {
+			res = append(res, "user agent looks like scripting tool: "+kw)
+			break
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func saveSessionsCSV(sessions []*vcSession) error {
+	wDstFilePath := filepath.Join("output", "Sessions_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Login Time", "Last Active Time", "User", "IP Address", "User Agent", "Locale",
+		"Call Count", "Extension Session", "Current Session", "Flags"})
+	if err != nil {
+		return err
+	}
+	for _, s := range sessions {
+		err = cwr.Write([]string{strconv.FormatInt(s.LoginTime.Unix(), 10),
+			strconv.FormatInt(s.LastActiveTime.Unix(), 10), s.UserName, s.IpAddress, s.UserAgent, s.Locale,
+			strconv.FormatInt(s.CallCount, 10), strconv.FormatBool(s.ExtensionSession),
+			strconv.FormatBool(s.IsCurrentSession), strings.Join(s.Flags, "AND")})
+		if err != nil {
+			log.Errorln("csv write error:", err)
+			continue
+		}
+	}
+	log.Infoln("sessions stored in csv: ", wDstFilePath)
+	return nil
+}
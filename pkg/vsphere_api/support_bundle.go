@@ -19,6 +19,7 @@ import (
 
 var (
 	ErrCreateGenerationTaskFailed = errors.New("create task for bundle generation failed")
+	ErrUnexpectedHTTPStatus       = errors.New("unexpected http response status code")
 )
 
 func (vsc *vSphereClient) RequestSupportBundle(hostList []*object.HostSystem, wg *sync.WaitGroup) error {
@@ -93,7 +94,40 @@ func (vsc *vSphereClient) downloadSupportBundle(bundlesInfo []types.DiagnosticMa
 
 func (vsc *vSphereClient) progressedDownloader(dstFile string, url string, dwnldWg *sync.WaitGroup) {
 	defer dwnldWg.Done()
-	ctx := context.Background()
+	// by default, the param for request build is only GET method, without any cookie
+	req, err := vsc.newDownloadRequest(url, false)
+	if err != nil {
+		log.Errorln("unknown error occurred when build requests, err: ", err)
+		return
+	}
+	finalDstFilePath := filepath.Join("output", dstFile)
+	_, err = vsc.progressedDownload(req, finalDstFilePath)
+	if err != nil {
+		log.Errorf("error while downloading %s from network: %v", dstFile, err)
+		return
+	}
+}
+
+// newDownloadRequest build GET request with user-agent, if withSession is true, current soap session cookie
+// will be attached, which is required by /folder and /host endpoint.
+func (vsc *vSphereClient) newDownloadRequest(url string, withSession bool) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", "DFIR4vSphere-Go/"+common.VersionStr)
+	req = req.WithContext(context.Background())
+	if withSession && vsc.vmwSoapClient.Client.Jar != nil {
+		for _, c := range vsc.vmwSoapClient.Client.Jar.Cookies(vsc.vmwSoapClient.URL()) {
+			req.AddCookie(c)
+		}
+	}
+	return req, nil
+}
+
+// progressedDownload send request and save response body to dstPath with progress bar, response body is also
+// written to extraW, e.g. hashers. Return bytes written.
+func (vsc *vSphereClient) progressedDownload(req *http.Request, dstPath string, extraW ...io.Writer) (int64, error) {
 	httpCli := http.DefaultClient
 	httpCli.Transport = http.DefaultTransport
 	if vsc.httpProxy != nil {
@@ -103,37 +137,24 @@ func (vsc *vSphereClient) progressedDownloader(dstFile string, url string, dwnld
 		ClientAuth:         tls.NoClientCert,
 		InsecureSkipVerify: vsc.skipTLS,
 	}
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		log.Errorln("unknown error occurred when build requests, err: ", err)
-		return
-	}
-	req.Header.Add("User-Agent", "DFIR4vSphere-Go/"+common.VersionStr)
-	req = req.WithContext(ctx)
-	// by default, the param for request build is only GET method, without any cookie
 	resp, err := httpCli.Do(req)
 	if err != nil {
 		log.Errorln("request sent, response err: ", err)
-		return
+		return 0, err
 	}
 	defer resp.Body.Close()
-	if resp.StatusCode == 200 {
-		finalDstFilePath := filepath.Join("output", dstFile)
-		f, err := os.OpenFile(finalDstFilePath, os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			log.Errorln("cannot write to / create dest file, err: ", err)
-			return
-		}
-		defer f.Close()
-		defer f.Sync()
-		pgBar := progressbar.DefaultBytes(resp.ContentLength, "downloading "+dstFile+" ...")
-		_, err = io.Copy(io.MultiWriter(f, pgBar), resp.Body)
-		if err != nil {
-			log.Errorf("error while downloading %s from network: %v", dstFile, err)
-			return
-		}
-	} else {
+	if resp.StatusCode != 200 {
 		log.Errorln("resp code not 200, currently: ", resp.StatusCode)
-		return
+		return 0, ErrUnexpectedHTTPStatus
+	}
+	f, err := os.OpenFile(dstPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		log.Errorln("cannot write to / create dest file, err: ", err)
+		return 0, err
 	}
+	defer f.Close()
+	defer f.Sync()
+	pgBar := progressbar.DefaultBytes(resp.ContentLength, "downloading "+filepath.Base(dstPath)+" ...")
+	dstWriters := append([]io.Writer{f, pgBar}, extraW...)
+	return io.Copy(io.MultiWriter(dstWriters...), resp.Body)
 }
@@ -1,9 +1,73 @@
 package vsphere_api
 
 import (
+	"context"
+	"encoding/json"
 	gonanoid "github.com/matoous/go-nanoid"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/find"
+	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func GetNanoID(length int) (string, error) {
 	return gonanoid.Generate("0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ", length)
 }
+
+// invtPathCache caches inventory path of managed object reference, since find.InventoryPath walks up to root folder
+// with a single round trip for each parent, and most collectors refer to the same entity multiple times.
+type invtPathCache map[types.ManagedObjectReference]string
+
+// Resolve returns inventory path of ref, if failed, return "Type:Value" of ref instead.
+func (ipc invtPathCache) Resolve(ref types.ManagedObjectReference) string {
+	if v, ok := ipc[ref]; ok {
+		return v
+	}
+	p, err := find.InventoryPath(context.Background(), GlobalClient.GetSOAPClient(), ref)
+	if err != nil {
+		log.Debugln("resolve inventory path for ", ref.String(), " failed, err: ", err)
+		p = ref.String()
+	}
+	ipc[ref] = p
+	return p
+}
+
+// safePathComponent replace characters not safe in a single local path component, names of inventory objects are
+// user controlled and may contain path separators.
+func safePathComponent(name string) string {
+	res := strings.Map(func(r rune) rune {
+		switch r {
+		case '/', '\\', ':', '*', '?', '"', '<', '>', '|', 0:
+			return '_'
+		}
+		return r
+	}, name)
+	if res == "" || res == "." || res == ".." {
+		return "_"
+	}
+	return res
+}
+
+// SaveJSONOutput marshal v with indent and save to output/<prefix>_<Unix Timestamp>.json, return the file path.
+func SaveJSONOutput(prefix string, v interface{}) (string, error) {
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return "", err
+	}
+	fDstPath := filepath.Join("output", prefix+"_"+strconv.FormatInt(time.Now().Unix(), 10)+".json")
+	fd, err := os.Create(fDstPath)
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close()
+	defer fd.Sync()
+	_, err = fd.Write(data)
+	if err != nil {
+		return "", err
+	}
+	return fDstPath, nil
+}
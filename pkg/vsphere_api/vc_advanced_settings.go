@@ -0,0 +1,180 @@
+package vsphere_api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	//go:embed vc_settings_baseline.json
+	vcSettingsBaselineRaw []byte
+	// vcLogLevelOrder is the verbosity order of vpxd log level, lower than recommended means weakened
+	vcLogLevelOrder = map[string]int{"none": 0, "quiet": 0, "error": 1, "warning": 2, "info": 3, "verbose": 4,
+		"trivia": 5}
+)
+
+type vcSettingBaseline struct {
+	Key         string `json:"key"`
+	Default     string `json:"default"`
+	Recommended string `json:"recommended"`
+	// Compare is one of: min, max, eq, level, info
+	Compare     string `json:"compare"`
+	Description string `json:"description"`
+}
+
+type VCAdvancedSettings struct {
+	Settings []*vcAdvancedSetting `json:"settings"`
+	Findings []*vcSettingFinding  `json:"findings,omitempty"`
+	// MissingBaselineKeys are keys in baseline but not returned by server
+	MissingBaselineKeys []string `json:"missing_baseline_keys,omitempty"`
+}
+
+type vcAdvancedSetting struct {
+	Key      string `json:"key"`
+	Value    string `json:"value"`
+	Type     string `json:"type"`
+	Category string `json:"category"`
+}
+
+type vcSettingFinding struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	Default     string `json:"default"`
+	Recommended string `json:"recommended,omitempty"`
+	// Status is one of: non_default, weakened, informational
+	Status      string `json:"status"`
+	Description string `json:"description,omitempty"`
+}
+
+func loadVCSettingsBaseline() (map[string]*vcSettingBaseline, error) {
+	var bls []*vcSettingBaseline
+	err := json.Unmarshal(vcSettingsBaselineRaw, &bls)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]*vcSettingBaseline, len(bls))
+	for _, v := range bls {
+		res[v.Key] = v
+	}
+	return res, nil
+}
+
+// ListAdvancedSettings dump all vCenter advanced settings using OptionManager.Query(""), then compare with bundled
+// baseline to highlight non-default and weakened settings.
+func (vsc *vSphereClient) ListAdvancedSettings(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	_ = vsc.NewVcsaOptionManager()
+	tmpCtx := context.Background()
+	opts, err := vsc.vcsaOptionMgr.Query(tmpCtx, "")
+	if err != nil {
+		return err
+	}
+	baseline, err := loadVCSettingsBaseline()
+	if err != nil {
+		return err
+	}
+	res := &VCAdvancedSettings{
+		Settings: make([]*vcAdvancedSetting, 0, len(opts)),
+		Findings: make([]*vcSettingFinding, 0),
+	}
+	seenKeys := make(map[string]bool, len(opts))
+	for i := range opts {
+		sOpt := opts[i].GetOptionValue()
+		vStr := fmt.Sprintf("%v", sOpt.Value)
+		res.Settings = append(res.Settings, &vcAdvancedSetting{
+			Key:      sOpt.Key,
+			Value:    vStr,
+			Type:     fmt.Sprintf("%T", sOpt.Value),
+			Category: vcSettingCategory(sOpt.Key),
+		})
+		seenKeys[sOpt.Key] = true
+		if bl, ok := baseline[sOpt.Key]; ok {
+			if f := compareVCSetting(bl, vStr); f != nil {
+				res.Findings = append(res.Findings, f)
+			}
+		}
+	}
+	for k := range baseline {
+		if !seenKeys[k] {
+			res.MissingBaselineKeys = append(res.MissingBaselineKeys, k)
+		}
+	}
+	sort.Slice(res.Settings, func(i, j int) bool {
+		return res.Settings[i].Key < res.Settings[j].Key
+	})
+	sort.Strings(res.MissingBaselineKeys)
+	for _, f := range res.Findings {
+		if f.Status == "weakened" {
+			log.Warnf("VCSA Option weakened: %s = %s, recommended: %s", f.Key, f.Value, f.Recommended)
+		}
+	}
+	log.Infof("VCSA Options: %d settings retrieved, %d findings.", len(res.Settings), len(res.Findings))
+	vcbi.AdvancedSettings = res
+	return nil
+}
+
+func vcSettingCategory(key string) string {
+	switch {
+	case strings.HasPrefix(key, "event.") || strings.HasPrefix(key, "task."):
+		return "event_task_retention"
+	case strings.HasPrefix(key, "config.log.") || strings.Contains(strings.ToLower(key), "log.level"):
+		return "logging"
+	case strings.HasPrefix(key, "config.vpxd."):
+		return "vpxd"
+	default:
+		return "other"
+	}
+}
+
+// compareVCSetting returns nil if value equals to default and is not weakened.
+func compareVCSetting(bl *vcSettingBaseline, value string) *vcSettingFinding {
+	f := &vcSettingFinding{
+		Key:         bl.Key,
+		Value:       value,
+		Default:     bl.Default,
+		Recommended: bl.Recommended,
+		Description: bl.Description,
+	}
+	weakened := false
+	switch bl.Compare {
+	case "info":
+		if value == "" {
+			return nil
+		}
+		f.Status = "informational"
+		return f
+	case "min", "max":
+		vNum, err1 := strconv.ParseFloat(value, 64)
+		rNum, err2 := strconv.ParseFloat(bl.Recommended, 64)
+		if err1 != nil || err2 != nil {
+			weakened = value != bl.Recommended
+		} else if bl.Compare == "min" {
+			weakened = vNum < rNum
+		} else {
+			weakened = vNum > rNum
+		}
+	case "level":
+		vLvl, ok1 := vcLogLevelOrder[strings.ToLower(value)]
+		rLvl, ok2 := vcLogLevelOrder[strings.ToLower(bl.Recommended)]
+		weakened = !ok1 || !ok2 || vLvl < rLvl
+	default:
+		weakened = !strings.EqualFold(value, bl.Recommended)
+	}
+	switch {
+	case weakened:
+		f.Status = "weakened"
+	case !strings.EqualFold(value, bl.Default):
+		f.Status = "non_default"
+	default:
+		return nil
+	}
+	return f
+}
@@ -0,0 +1,339 @@
+package vsphere_api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	ErrAlarmMgrNotAvailable = errors.New("alarm manager is not available on this server")
+)
+
+var (
+	// alarmVIEventTypesId contains all alarm related events, alarm actions can run script or send mail, which
+	// is a possible persistence vector.
+	alarmVIEventTypesId = []string{"AlarmCreatedEvent", "AlarmReconfiguredEvent", "AlarmRemovedEvent",
+		"AlarmStatusChangedEvent", "AlarmAcknowledgedEvent", "AlarmClearedEvent", "AlarmActionTriggeredEvent",
+		"AlarmScriptCompleteEvent", "AlarmScriptFailedEvent", "AlarmEmailCompletedEvent", "AlarmEmailFailedEvent",
+		"AlarmSnmpCompletedEvent", "AlarmSnmpFailedEvent"}
+)
+
+type VCAlarmInventory struct {
+	Definitions []*vcAlarmDefinition `json:"definitions,omitempty"`
+	Triggered   []*vcTriggeredAlarm  `json:"triggered,omitempty"`
+	Events      []*vcAlarmEvent      `json:"events,omitempty"`
+}
+
+type vcAlarmDefinition struct {
+	Key              string             `json:"key"`
+	Name             string             `json:"name"`
+	SystemName       string             `json:"system_name,omitempty"`
+	Description      string             `json:"description,omitempty"`
+	Enabled          bool               `json:"enabled"`
+	DefinedOn        string             `json:"defined_on"`
+	LastModifiedTime time.Time          `json:"last_modified_time"`
+	LastModifiedUser string             `json:"last_modified_user"`
+	CreationEventId  int32              `json:"creation_event_id"`
+	ActionFrequency  int32              `json:"action_frequency,omitempty"`
+	Expression       *vcAlarmExpression `json:"expression,omitempty"`
+	Actions          []*vcAlarmAction   `json:"actions,omitempty"`
+	// HasRiskyAction is true if any action runs script, invokes method or sends mail
+	HasRiskyAction bool `json:"has_risky_action"`
+}
+
+type vcAlarmExpression struct {
+	Type        string               `json:"type"`
+	Operator    string               `json:"operator,omitempty"`
+	ObjectType  string               `json:"object_type,omitempty"`
+	Detail      string               `json:"detail,omitempty"`
+	Yellow      string               `json:"yellow,omitempty"`
+	Red         string               `json:"red,omitempty"`
+	Comparisons []string             `json:"comparisons,omitempty"`
+	Children    []*vcAlarmExpression `json:"children,omitempty"`
+}
+
+type vcAlarmAction struct {
+	Type        string   `json:"type"`
+	Detail      string   `json:"detail,omitempty"`
+	Transitions []string `json:"transitions,omitempty"`
+	Risky       bool     `json:"risky"`
+}
+
+type vcTriggeredAlarm struct {
+	Key                string     `json:"key"`
+	AlarmName          string     `json:"alarm_name"`
+	Entity             string     `json:"entity"`
+	OverallStatus      string     `json:"overall_status"`
+	Time               time.Time  `json:"time"`
+	Acknowledged       bool       `json:"acknowledged"`
+	AcknowledgedByUser string     `json:"acknowledged_by_user,omitempty"`
+	AcknowledgedTime   *time.Time `json:"acknowledged_time,omitempty"`
+	EventKey           int32      `json:"event_key,omitempty"`
+}
+
+type vcAlarmEvent struct {
+	EventID     int32     `json:"event_id"`
+	CreatedTime time.Time `json:"created_time"`
+	EventType   string    `json:"event_type"`
+	UserName    string    `json:"user_name,omitempty"`
+	AlarmName   string    `json:"alarm_name,omitempty"`
+	Message     string    `json:"message"`
+}
+
+// ListAlarms enumerates alarm definitions on every entity, currently triggered alarm states and alarm related events.
+func (vsc *vSphereClient) ListAlarms(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if vsc.vmwSoapClient.ServiceContent.AlarmManager == nil {
+		return ErrAlarmMgrNotAvailable
+	}
+	tmpCtx := context.Background()
+	alarmMgrRef := *vsc.vmwSoapClient.ServiceContent.AlarmManager
+	pathCache := make(invtPathCache)
+	res := &VCAlarmInventory{}
+	// alarm definitions, if entity is not set, all alarms visible to current user are returned
+	// failure here should not stop triggered alarm states from being collected
+	alarmNames := make(map[types.ManagedObjectReference]string)
+	alarmResp, err := methods.GetAlarm(tmpCtx, vsc.vmwSoapClient, &types.GetAlarm{This: alarmMgrRef})
+	if err != nil {
+		log.Errorln("get alarm list from alarm manager, err: ", err)
+	} else if len(alarmResp.Returnval) != 0 {
+		log.Debugf("ListAlarms: %d alarms retrieved.", len(alarmResp.Returnval))
+		var alarmObjs []mo.Alarm
+		coll := property.DefaultCollector(vsc.vmwSoapClient)
+		err = coll.Retrieve(tmpCtx, alarmResp.Returnval, []string{"info"}, &alarmObjs)
+		if err != nil {
+			log.Errorln("retrieve alarm info, err: ", err)
+		}
+		res.Definitions = make([]*vcAlarmDefinition, 0, len(alarmObjs))
+		for i := range alarmObjs {
+			alarmNames[alarmObjs[i].Self] = alarmObjs[i].Info.Name
+			res.Definitions = append(res.Definitions, convertAlarmInfo2External(&alarmObjs[i].Info, pathCache))
+		}
+		log.Infoln("alarm definitions retrieved.")
+	}
+	// triggered alarm states on every managed entity
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(tmpCtx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"ManagedEntity"}, true)
+	if err != nil {
+		log.Errorln("create container view for managed entity, err: ", err)
+		return err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpCtx)
+	}()
+	var entities []mo.ManagedEntity
+	err = ctnrView.Retrieve(tmpCtx, []string{"ManagedEntity"}, []string{"triggeredAlarmState"}, &entities)
+	if err != nil {
+		log.Errorln("retrieve triggered alarm state, err: ", err)
+		return err
+	}
+	// root folder is not included in container view
+	var rootFolder mo.Folder
+	err = property.DefaultCollector(vsc.vmwSoapClient).RetrieveOne(tmpCtx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"triggeredAlarmState"}, &rootFolder)
+	if err != nil {
+		log.Errorln("retrieve triggered alarm state on root folder, err: ", err)
+	} else {
+		entities = append(entities, rootFolder.ManagedEntity)
+	}
+	// triggered alarm state is propagated to parent entity, use key for deduplication
+	seenAlarmState := make(map[string]struct{})
+	res.Triggered = make([]*vcTriggeredAlarm, 0)
+	for _, e := range entities {
+		for _, st := range e.TriggeredAlarmState {
+			if _, ok := seenAlarmState[st.Key]; ok {
+				continue
+			}
+			seenAlarmState[st.Key] = struct{}{}
+			res.Triggered = append(res.Triggered, &vcTriggeredAlarm{
+				Key:                st.Key,
+				AlarmName:          alarmNames[st.Alarm],
+				Entity:             pathCache.Resolve(st.Entity),
+				OverallStatus:      string(st.OverallStatus),
+				Time:               st.Time,
+				Acknowledged:       st.Acknowledged != nil && *st.Acknowledged,
+				AcknowledgedByUser: st.AcknowledgedByUser,
+				AcknowledgedTime:   st.AcknowledgedTime,
+				EventKey:           st.EventKey,
+			})
+		}
+	}
+	log.Infoln("triggered alarm states retrieved.")
+	// alarm related events, only vCenter keeps history of those events
+	if vsc.IsVCenter() {
+		var beginTime *time.Time
+		if vsc.evntMaxAge > 0 {
+			serverNow, err := methods.GetCurrentTime(tmpCtx, vsc.vmwSoapClient)
+			if err == nil {
+				startFrom := serverNow.AddDate(0, 0, -vsc.evntMaxAge)
+				beginTime = &startFrom
+			}
+		}
+		evnts, err := vsc.collectEventsByTypeId(vsc.vmwSoapClient.ServiceContent.RootFolder, alarmVIEventTypesId,
+			beginTime, nil)
+		if err != nil {
+			log.Errorln("collect alarm related events, err: ", err)
+		}
+		res.Events = make([]*vcAlarmEvent, 0, len(evnts))
+		for _, be := range evnts {
+			res.Events = append(res.Events, convertAlarmEvent2External(be))
+		}
+		log.Infoln("alarm related events retrieved.")
+	}
+	vcbi.Alarms = res
+	return nil
+}
+
+func convertAlarmInfo2External(ai *types.AlarmInfo, pathCache invtPathCache) *vcAlarmDefinition {
+	res := &vcAlarmDefinition{
+		Key:              ai.Key,
+		Name:             ai.Name,
+		SystemName:       ai.SystemName,
+		Description:      ai.Description,
+		Enabled:          ai.Enabled,
+		DefinedOn:        pathCache.Resolve(ai.Entity),
+		LastModifiedTime: ai.LastModifiedTime,
+		LastModifiedUser: ai.LastModifiedUser,
+		CreationEventId:  ai.CreationEventId,
+		ActionFrequency:  ai.ActionFrequency,
+		Expression:       convertAlarmExpression2External(ai.Expression),
+		Actions:          convertAlarmAction2External(ai.Action),
+	}
+	for _, v := range res.Actions {
+		if v.Risky {
+			res.HasRiskyAction = true
+			break
+		}
+	}
+	return res
+}
+
+func convertAlarmExpression2External(be types.BaseAlarmExpression) *vcAlarmExpression {
+	if be == nil {
+		return nil
+	}
+	switch expr := be.(type) {
+	case *types.AndAlarmExpression:
+		res := &vcAlarmExpression{Type: "and"}
+		for _, sub := range expr.Expression {
+			res.Children = append(res.Children, convertAlarmExpression2External(sub))
+		}
+		return res
+	case *types.OrAlarmExpression:
+		res := &vcAlarmExpression{Type: "or"}
+		for _, sub := range expr.Expression {
+			res.Children = append(res.Children, convertAlarmExpression2External(sub))
+		}
+		return res
+	case *types.EventAlarmExpression:
+		res := &vcAlarmExpression{
+			Type:       "event",
+			ObjectType: expr.ObjectType,
+			Detail: func() string {
+				if expr.EventTypeId != "" {
+					return expr.EventTypeId
+				}
+				return expr.EventType
+			}(),
+			Red: string(expr.Status),
+		}
+		for _, c := range expr.Comparisons {
+			res.Comparisons = append(res.Comparisons, c.AttributeName+" "+c.Operator+" "+c.Value)
+		}
+		return res
+	case *types.MetricAlarmExpression:
+		return &vcAlarmExpression{
+			Type:       "metric",
+			Operator:   string(expr.Operator),
+			ObjectType: expr.Type,
+			Detail:     fmt.Sprintf("counter=%d instance=%s", expr.Metric.CounterId, expr.Metric.Instance),
+			Yellow:     fmt.Sprintf("%d (interval %ds)", expr.Yellow, expr.YellowInterval),
+			Red:        fmt.Sprintf("%d (interval %ds)", expr.Red, expr.RedInterval),
+		}
+	case *types.StateAlarmExpression:
+		return &vcAlarmExpression{
+			Type:       "state",
+			Operator:   string(expr.Operator),
+			ObjectType: expr.Type,
+			Detail:     expr.StatePath,
+			Yellow:     expr.Yellow,
+			Red:        expr.Red,
+		}
+	default:
+		return &vcAlarmExpression{Type: reflect.TypeOf(be).Elem().Name()}
+	}
+}
+
+func convertAlarmAction2External(ba types.BaseAlarmAction) []*vcAlarmAction {
+	if ba == nil {
+		return nil
+	}
+	switch act := ba.(type) {
+	case *types.GroupAlarmAction:
+		res := make([]*vcAlarmAction, 0)
+		for _, sub := range act.Action {
+			res = append(res, convertAlarmAction2External(sub)...)
+		}
+		return res
+	case *types.AlarmTriggeringAction:
+		res := &vcAlarmAction{}
+		switch a := act.Action.(type) {
+		case *types.RunScriptAction:
+			res.Type = "run_script"
+			res.Detail = a.Script
+			res.Risky = true
+		case *types.SendEmailAction:
+			res.Type = "send_email"
+			res.Detail = fmt.Sprintf("to=%s cc=%s subject=%s", a.ToList, a.CcList, a.Subject)
+			res.Risky = true
+		case *types.MethodAction:
+			res.Type = "method"
+			res.Detail = a.Name
+			res.Risky = true
+		case *types.CreateTaskAction:
+			res.Type = "create_task"
+			res.Detail = a.TaskTypeId
+		case *types.SendSNMPAction:
+			res.Type = "send_snmp"
+		default:
+			if act.Action != nil {
+				res.Type = reflect.TypeOf(act.Action).Elem().Name()
+			}
+		}
+		for _, ts := range act.TransitionSpecs {
+			res.Transitions = append(res.Transitions, fmt.Sprintf("%s->%s (repeats: %v)", ts.StartState,
+				ts.FinalState, ts.Repeats))
+		}
+		return []*vcAlarmAction{res}
+	default:
+		return []*vcAlarmAction{{Type: reflect.TypeOf(ba).Elem().Name()}}
+	}
+}
+
+func convertAlarmEvent2External(be types.BaseEvent) *vcAlarmEvent {
+	e := be.GetEvent()
+	res := &vcAlarmEvent{
+		EventID:     e.Key,
+		CreatedTime: e.CreatedTime,
+		EventType:   reflect.TypeOf(be).Elem().Name(),
+		UserName:    e.UserName,
+		Message:     strings.TrimSpace(e.FullFormattedMessage),
+	}
+	if ae, ok := be.(types.BaseAlarmEvent); ok {
+		res.AlarmName = ae.GetAlarmEvent().Alarm.Name
+	}
+	return res
+}
@@ -0,0 +1,342 @@
+package vsphere_api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrApplianceNotLoggedIn = errors.New("appliance rest client does NOT have active session")
+)
+
+var (
+	// applianceRequestTimeout limits every single request to appliance management api
+	applianceRequestTimeout = 30 * time.Second
+)
+
+type VCApplianceConfig struct {
+	Version *vcApplianceVersion `json:"version,omitempty"`
+	// Access contains ssh, bash shell, console cli and dcui settings
+	Access              *vcApplianceAccess              `json:"access,omitempty"`
+	LocalAccountsPolicy *vcApplianceLocalAccountsPolicy `json:"local_accounts_policy,omitempty"`
+	LocalAccounts       []*vcApplianceLocalAccount      `json:"local_accounts,omitempty"`
+	SyslogForwarding    []*vcApplianceSyslogForwarding  `json:"syslog_forwarding,omitempty"`
+	TimeSyncMode        string                          `json:"time_sync_mode,omitempty"`
+	NTPServers          []string                        `json:"ntp_servers,omitempty"`
+	FirewallInbound     []*vcApplianceFirewallRule      `json:"firewall_inbound,omitempty"`
+	BackupSchedules     map[string]*vcApplianceBackup   `json:"backup_schedules,omitempty"`
+	Services            map[string]*vcApplianceService  `json:"services,omitempty"`
+	// Errors are endpoints failed to query, by api path
+	Errors map[string]string `json:"errors,omitempty"`
+	Flags  []string          `json:"flags,omitempty"`
+}
+
+type vcApplianceVersion struct {
+	Product     string `json:"product"`
+	Version     string `json:"version"`
+	Build       string `json:"build"`
+	Type        string `json:"type"`
+	ReleaseDate string `json:"releasedate,omitempty"`
+	InstallTime string `json:"install_time,omitempty"`
+}
+
+type vcApplianceAccess struct {
+	SSH        *bool                   `json:"ssh,omitempty"`
+	ConsoleCLI *bool                   `json:"console_cli,omitempty"`
+	DCUI       *bool                   `json:"dcui,omitempty"`
+	Shell      *vcApplianceShellAccess `json:"shell,omitempty"`
+}
+
+type vcApplianceShellAccess struct {
+	Enabled bool `json:"enabled"`
+	// Timeout is the remaining seconds before shell is disabled automatically
+	Timeout int64 `json:"timeout"`
+}
+
+type vcApplianceLocalAccountsPolicy struct {
+	MaxDays  *int64 `json:"max_days,omitempty"`
+	MinDays  *int64 `json:"min_days,omitempty"`
+	WarnDays *int64 `json:"warn_days,omitempty"`
+}
+
+type vcApplianceLocalAccount struct {
+	Username                         string   `json:"username"`
+	FullName                         string   `json:"fullname,omitempty"`
+	Email                            string   `json:"email,omitempty"`
+	Roles                            []string `json:"roles,omitempty"`
+	Enabled                          bool     `json:"enabled"`
+	HasPassword                      bool     `json:"has_password"`
+	LastPasswordChange               string   `json:"last_password_change,omitempty"`
+	PasswordExpiresAt                string   `json:"password_expires_at,omitempty"`
+	InactiveAt                       string   `json:"inactive_at,omitempty"`
+	MinDaysBetweenPasswordChange     *int64   `json:"min_days_between_password_change,omitempty"`
+	MaxDaysBetweenPasswordChange     *int64   `json:"max_days_between_password_change,omitempty"`
+	WarnDaysBeforePasswordExpiration *int64   `json:"warn_days_before_password_expiration,omitempty"`
+	Error                            string   `json:"error,omitempty"`
+}
+
+type vcApplianceSyslogForwarding struct {
+	Hostname string `json:"hostname"`
+	Port     int64  `json:"port"`
+	Protocol string `json:"protocol"`
+}
+
+type vcApplianceFirewallRule struct {
+	Address       string `json:"address"`
+	Prefix        int64  `json:"prefix"`
+	Policy        string `json:"policy"`
+	InterfaceName string `json:"interface_name,omitempty"`
+}
+
+type vcApplianceBackup struct {
+	Parts    []string `json:"parts,omitempty"`
+	Location string   `json:"location"`
+	Enable   bool     `json:"enable"`
+	// RecurrenceInfo and RetentionInfo are kept as returned by api
+	RecurrenceInfo json.RawMessage `json:"recurrence_info,omitempty"`
+	RetentionInfo  json.RawMessage `json:"retention_info,omitempty"`
+}
+
+type vcApplianceService struct {
+	Description string `json:"description"`
+	State       string `json:"state"`
+}
+
+// applianceRESTClient talks to VCSA appliance management api (VAMI) at /api/appliance, base url and http client
+// are injectable so that it can be pointed to any endpoint.
+type applianceRESTClient struct {
+	baseURL   *url.URL
+	httpCli   *http.Client
+	user      *url.Userinfo
+	sessionId string
+}
+
+func newApplianceRESTClient(baseURL *url.URL, httpCli *http.Client, user *url.Userinfo) *applianceRESTClient {
+	if httpCli == nil {
+		httpCli = &http.Client{Transport: http.DefaultTransport, Timeout: applianceRequestTimeout}
+	}
+	return &applianceRESTClient{baseURL: baseURL, httpCli: httpCli, user: user}
+}
+
+func (ac *applianceRESTClient) do(method string, apiPath string, out interface{}) error {
+	u := *ac.baseURL
+	u.Path = strings.TrimSuffix(u.Path, "/") + apiPath
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if ac.sessionId != "" {
+		req.Header.Set("vmware-api-session-id", ac.sessionId)
+	} else if ac.user != nil {
+		password, _ := ac.user.Password()
+		req.SetBasicAuth(ac.user.Username(), password)
+	}
+	resp, err := ac.httpCli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return errors.New("unexpected response status: " + resp.Status + ", " + string(bytes.TrimSpace(data)))
+	}
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// Login create api session with basic authentication, session id is used for all subsequent requests.
+func (ac *applianceRESTClient) Login() error {
+	if ac.user == nil {
+		return ErrCredentialNotFound
+	}
+	var sessId string
+	err := ac.do(http.MethodPost, "/api/session", &sessId)
+	if err != nil {
+		return err
+	}
+	if sessId == "" {
+		return ErrApplianceNotLoggedIn
+	}
+	ac.sessionId = sessId
+	return nil
+}
+
+func (ac *applianceRESTClient) Logout() error {
+	if ac.sessionId == "" {
+		return ErrApplianceNotLoggedIn
+	}
+	err := ac.do(http.MethodDelete, "/api/session", nil)
+	ac.sessionId = ""
+	return err
+}
+
+func (ac *applianceRESTClient) Get(apiPath string, out interface{}) error {
+	if ac.sessionId == "" {
+		return ErrApplianceNotLoggedIn
+	}
+	return ac.do(http.MethodGet, apiPath, out)
+}
+
+// ListApplianceConfig collect security relevant configuration of vCenter Server Appliance from appliance
+// management api, with credentials of current session.
+func (vsc *vSphereClient) ListApplianceConfig(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if vsc.soapURL == nil || vsc.soapURL.User == nil {
+		return ErrCredentialNotFound
+	}
+	baseURL := &url.URL{Scheme: vsc.soapURL.Scheme, Host: vsc.soapURL.Host}
+	ac := newApplianceRESTClient(baseURL, nil, vsc.soapURL.User)
+	res, err := collectApplianceConfig(ac)
+	if err != nil {
+		return err
+	}
+	for _, f := range res.Flags {
+		log.Warnln("appliance configuration flagged: ", f)
+	}
+	vcbi.Appliance = res
+	return nil
+}
+
+// collectApplianceConfig login, query every endpoint and logout. Failure of single endpoint is recorded and does
+// not stop the collection, since some endpoints are missing on older releases.
+func collectApplianceConfig(ac *applianceRESTClient) (*VCApplianceConfig, error) {
+	err := ac.Login()
+	if err != nil {
+		log.Errorln("login to appliance api, err: ", err)
+		return nil, err
+	}
+	defer func() {
+		err := ac.Logout()
+		if err != nil {
+			log.Errorln("logout from appliance api, err: ", err)
+		}
+	}()
+	res := &VCApplianceConfig{Access: &vcApplianceAccess{}, Errors: make(map[string]string)}
+	get := func(apiPath string, out interface{}) bool {
+		err := ac.Get(apiPath, out)
+		if err != nil {
+			log.Errorln("query appliance api ", apiPath, ", err: ", err)
+			res.Errors[apiPath] = err.Error()
+			return false
+		}
+		log.Debugln("executed: query appliance api ", apiPath)
+		return true
+	}
+	res.Version = &vcApplianceVersion{}
+	if !get("/api/appliance/system/version", res.Version) {
+		res.Version = nil
+	}
+	// access
+	get("/api/appliance/access/ssh", &res.Access.SSH)
+	get("/api/appliance/access/consolecli", &res.Access.ConsoleCLI)
+	get("/api/appliance/access/dcui", &res.Access.DCUI)
+	get("/api/appliance/access/shell", &res.Access.Shell)
+	// local accounts
+	get("/api/appliance/local-accounts/global-policy", &res.LocalAccountsPolicy)
+	var accNames []string
+	if get("/api/appliance/local-accounts", &accNames) {
+		sort.Strings(accNames)
+		for _, name := range accNames {
+			acc := &vcApplianceLocalAccount{}
+			err := ac.Get("/api/appliance/local-accounts/"+url.PathEscape(name), acc)
+			if err != nil {
+				log.Errorln("get appliance local account ", name, ", err: ", err)
+				acc.Error = err.Error()
+			}
+			acc.Username = name
+			res.LocalAccounts = append(res.LocalAccounts, acc)
+		}
+	}
+	// logging and time
+	get("/api/appliance/logging/forwarding", &res.SyslogForwarding)
+	get("/api/appliance/timesync", &res.TimeSyncMode)
+	get("/api/appliance/ntp", &res.NTPServers)
+	// network
+	get("/api/appliance/networking/firewall/inbound", &res.FirewallInbound)
+	// backup
+	get("/api/appliance/recovery/backup/schedules", &res.BackupSchedules)
+	// services
+	get("/api/appliance/services", &res.Services)
+	if len(res.Errors) == 0 {
+		res.Errors = nil
+	}
+	res.Flags = applianceConfigFlags(res, time.Now())
+	log.Infof("appliance configuration collected, %d local accounts, %d services, %d endpoints failed.",
+		len(res.LocalAccounts), len(res.Services), len(res.Errors))
+	return res, nil
+}
+
+func applianceConfigFlags(c *VCApplianceConfig, now time.Time) []string {
+	res := make([]string, 0)
+	if c.Access != nil {
+		if c.Access.SSH != nil && *c.Access.SSH {
+			res = append(res, "ssh access is enabled")
+		}
+		if c.Access.Shell != nil && c.Access.Shell.Enabled {
+			res = append(res, "bash shell access is enabled")
+		}
+	}
+	for _, acc := range c.LocalAccounts {
+		if !acc.Enabled || acc.Error != "" {
+			continue
+		}
+		if acc.PasswordExpiresAt == "" {
+			res = append(res, "password of local account "+acc.Username+" never expires")
+		} else if t, err := time.Parse(time.RFC3339, acc.PasswordExpiresAt); err == nil && t.Before(now) {
+			res = append(res, "password of local account "+acc.Username+" has expired")
+		}
+		if acc.Username != "root" {
+			res = append(res, "additional local account is enabled: "+acc.Username)
+		}
+	}
+	if _, failed := c.Errors["/api/appliance/logging/forwarding"]; !failed {
+		if len(c.SyslogForwarding) == 0 {
+			res = append(res, "syslog forwarding is not configured")
+		}
+		for _, sf := range c.SyslogForwarding {
+			if !strings.EqualFold(sf.Protocol, "TLS") {
+				res = append(res, "syslog forwarded without encryption to "+sf.Hostname+" via "+sf.Protocol)
+			}
+		}
+	}
+	if strings.EqualFold(c.TimeSyncMode, "DISABLED") {
+		res = append(res, "time synchronization is disabled")
+	}
+	for _, r := range c.FirewallInbound {
+		if strings.EqualFold(r.Policy, "ACCEPT") && r.Prefix == 0 {
+			res = append(res, "firewall accepts inbound traffic from any address: "+r.Address)
+		}
+	}
+	if _, failed := c.Errors["/api/appliance/recovery/backup/schedules"]; !failed {
+		if len(c.BackupSchedules) == 0 {
+			res = append(res, "no backup schedule configured")
+		}
+		for id, b := range c.BackupSchedules {
+			loc := strings.ToLower(b.Location)
+			if strings.HasPrefix(loc, "ftp://") || strings.HasPrefix(loc, "http://") {
+				res = append(res, "backup schedule "+id+" uses plaintext protocol: "+b.Location)
+			}
+		}
+	}
+	sort.Strings(res)
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,298 @@
+package vsphere_api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testApplianceSessionId = "b0a9c4f1e2d3"
+
+// fakeApplianceAPI serves canned appliance api responses, requests without valid session are rejected.
+type fakeApplianceAPI struct {
+	mu        sync.Mutex
+	responses map[string]string
+	failures  map[string]int
+	logins    int
+	logouts   int
+	// unauthorized are api paths requested without session id
+	unauthorized []string
+}
+
+func (f *fakeApplianceAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	w.Header().Set("Content-Type", "application/json")
+	if r.URL.Path == "/api/session" {
+		switch r.Method {
+		case http.MethodPost:
+			user, password, ok := r.BasicAuth()
+			if !ok || user != "administrator@vsphere.local" || password != "P@ssw0rd" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			f.logins++
+			_, _ = w.Write([]byte(`"` + testApplianceSessionId + `"`))
+		case http.MethodDelete:
+			if r.Header.Get("vmware-api-session-id") != testApplianceSessionId {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			f.logouts++
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if r.Header.Get("vmware-api-session-id") != testApplianceSessionId {
+		f.unauthorized = append(f.unauthorized, r.URL.Path)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if code, ok := f.failures[r.URL.Path]; ok {
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(`{"error_type":"SERVICE_UNAVAILABLE"}`))
+		return
+	}
+	body, ok := f.responses[r.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error_type":"NOT_FOUND"}`))
+		return
+	}
+	_, _ = w.Write([]byte(body))
+}
+
+func newFakeApplianceAPI() *fakeApplianceAPI {
+	return &fakeApplianceAPI{
+		responses: map[string]string{
+			"/api/appliance/system/version": `{"product":"VMware vCenter Server","version":"7.0.3.01400",` +
+				`"build":"21477706","type":"vCenter Server with an embedded Platform Services Controller",` +
+				`"releasedate":"March 30, 2023","install_time":"2023-05-04T08:12:31.412Z"}`,
+			"/api/appliance/access/ssh":                   `true`,
+			"/api/appliance/access/consolecli":            `true`,
+			"/api/appliance/access/dcui":                  `false`,
+			"/api/appliance/access/shell":                 `{"enabled":true,"timeout":3540}`,
+			"/api/appliance/local-accounts/global-policy": `{"max_days":90,"min_days":0,"warn_days":7}`,
+			"/api/appliance/local-accounts":               `["root","backdoor","svc.monitor"]`,
+			"/api/appliance/local-accounts/root": `{"fullname":"root","email":"","roles":["superAdmin"],` +
+				`"enabled":true,"has_password":true,"last_password_change":"2023-05-04T00:00:00.000Z",` +
+				`"password_expires_at":"2023-08-02T00:00:00.000Z","inactive_at":"2023-08-02T00:00:00.000Z",` +
+				`"min_days_between_password_change":0,"max_days_between_password_change":90,` +
+				`"warn_days_before_password_expiration":7}`,
+			"/api/appliance/local-accounts/backdoor": `{"fullname":"backdoor","roles":["superAdmin"],` +
+				`"enabled":true,"has_password":true,"last_password_change":"2023-07-01T00:00:00.000Z"}`,
+			"/api/appliance/logging/forwarding": `[{"hostname":"10.0.0.20","port":514,"protocol":"UDP"}]`,
+			"/api/appliance/timesync":           `"DISABLED"`,
+			"/api/appliance/networking/firewall/inbound": `[{"address":"0.0.0.0","prefix":0,"policy":"ACCEPT",` +
+				`"interface_name":"nic0"}]`,
+			"/api/appliance/recovery/backup/schedules": `{"default":{"parts":["seat"],` +
+				`"location":"ftp://10.0.0.5/vcsa","enable":true,` +
+				`"recurrence_info":{"hour":23,"minute":0,"days":["MONDAY"]},"retention_info":{"max_count":3}}}`,
+			"/api/appliance/services": `{"vmware-vpxd":{"description":"vCenter Server","state":"STARTED"},` +
+				`"sshd":{"description":"SSH","state":"STARTED"}}`,
+		},
+		// ntp fails, account svc.monitor is missing and answered with 404
+		failures: map[string]int{"/api/appliance/ntp": http.StatusServiceUnavailable},
+	}
+}
+
+func newTestApplianceClient(srv *httptest.Server, user *url.Userinfo) *applianceRESTClient {
+	baseURL, _ := url.Parse(srv.URL)
+	return newApplianceRESTClient(baseURL, srv.Client(), user)
+}
+
+func TestApplianceRESTClientSession(t *testing.T) {
+	api := newFakeApplianceAPI()
+	srv := httptest.NewTLSServer(api)
+	defer srv.Close()
+	ac := newTestApplianceClient(srv, url.UserPassword("administrator@vsphere.local", "P@ssw0rd"))
+	var ssh bool
+	if err := ac.Get("/api/appliance/access/ssh", &ssh); err != ErrApplianceNotLoggedIn {
+		t.Errorf("get before login: got err %v, expected %v", err, ErrApplianceNotLoggedIn)
+	}
+	if err := ac.Login(); err != nil {
+		t.Fatal(err)
+	}
+	if ac.sessionId != testApplianceSessionId {
+		t.Errorf("session id: got %s, expected %s", ac.sessionId, testApplianceSessionId)
+	}
+	if err := ac.Get("/api/appliance/access/ssh", &ssh); err != nil || !ssh {
+		t.Errorf("get ssh access: got %v, err %v", ssh, err)
+	}
+	if err := ac.Logout(); err != nil {
+		t.Fatal(err)
+	}
+	if ac.sessionId != "" {
+		t.Error("session id is not cleared after logout")
+	}
+	if api.logins != 1 || api.logouts != 1 {
+		t.Errorf("got %d logins and %d logouts, expected 1 each", api.logins, api.logouts)
+	}
+	if len(api.unauthorized) != 0 {
+		t.Errorf("requests without session id: %v", api.unauthorized)
+	}
+	// wrong password
+	ac = newTestApplianceClient(srv, url.UserPassword("administrator@vsphere.local", "wrong"))
+	if err := ac.Login(); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("login with wrong password: got err %v, expected 401", err)
+	}
+}
+
+func TestCollectApplianceConfig(t *testing.T) {
+	api := newFakeApplianceAPI()
+	srv := httptest.NewTLSServer(api)
+	defer srv.Close()
+	ac := newTestApplianceClient(srv, url.UserPassword("administrator@vsphere.local", "P@ssw0rd"))
+	c, err := collectApplianceConfig(ac)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if api.logins != 1 || api.logouts != 1 {
+		t.Errorf("got %d logins and %d logouts, expected 1 each", api.logins, api.logouts)
+	}
+	if len(api.unauthorized) != 0 {
+		t.Errorf("requests without session id: %v", api.unauthorized)
+	}
+	// failed endpoint is recorded, the rest are still collected
+	if len(c.Errors) != 1 || !strings.Contains(c.Errors["/api/appliance/ntp"], "503") {
+		t.Errorf("errors: got %v, expected 503 of /api/appliance/ntp only", c.Errors)
+	}
+	if c.NTPServers != nil {
+		t.Errorf("ntp servers: got %v, expected nil", c.NTPServers)
+	}
+	if c.Version == nil || c.Version.Build != "21477706" {
+		t.Errorf("version: got %+v", c.Version)
+	}
+	if c.Access.SSH == nil || !*c.Access.SSH || c.Access.DCUI == nil || *c.Access.DCUI {
+		t.Errorf("access: got ssh %v, dcui %v", c.Access.SSH, c.Access.DCUI)
+	}
+	if c.Access.Shell == nil || !c.Access.Shell.Enabled || c.Access.Shell.Timeout != 3540 {
+		t.Errorf("shell access: got %+v", c.Access.Shell)
+	}
+	if c.LocalAccountsPolicy == nil || c.LocalAccountsPolicy.MaxDays == nil || *c.LocalAccountsPolicy.MaxDays != 90 {
+		t.Errorf("local accounts policy: got %+v", c.LocalAccountsPolicy)
+	}
+	if len(c.LocalAccounts) != 3 {
+		t.Fatalf("got %d local accounts, expected 3", len(c.LocalAccounts))
+	}
+	// sorted by name
+	backdoor, root, svc := c.LocalAccounts[0], c.LocalAccounts[1], c.LocalAccounts[2]
+	if backdoor.Username != "backdoor" || !backdoor.Enabled || backdoor.PasswordExpiresAt != "" {
+		t.Errorf("account backdoor: got %+v", backdoor)
+	}
+	if root.Username != "root" || !reflect.DeepEqual(root.Roles, []string{"superAdmin"}) || !root.HasPassword ||
+		root.PasswordExpiresAt != "2023-08-02T00:00:00.000Z" || root.MaxDaysBetweenPasswordChange == nil ||
+		*root.MaxDaysBetweenPasswordChange != 90 {
+		t.Errorf("account root: got %+v", root)
+	}
+	if svc.Username != "svc.monitor" || !strings.Contains(svc.Error, "404") {
+		t.Errorf("account svc.monitor: got %+v, expected 404 error", svc)
+	}
+	if len(c.SyslogForwarding) != 1 || c.SyslogForwarding[0].Protocol != "UDP" {
+		t.Errorf("syslog forwarding: got %+v", c.SyslogForwarding)
+	}
+	if c.TimeSyncMode != "DISABLED" {
+		t.Errorf("time sync mode: got %s", c.TimeSyncMode)
+	}
+	b := c.BackupSchedules["default"]
+	if len(c.BackupSchedules) != 1 || b == nil || b.Location != "ftp://10.0.0.5/vcsa" || !b.Enable ||
+		!reflect.DeepEqual(b.Parts, []string{"seat"}) || string(b.RetentionInfo) != `{"max_count":3}` {
+		t.Errorf("backup schedules: got %+v", c.BackupSchedules)
+	}
+	if s := c.Services["sshd"]; len(c.Services) != 2 || s == nil || s.State != "STARTED" {
+		t.Errorf("services: got %+v", c.Services)
+	}
+}
+
+func TestCollectApplianceConfigLoginFailure(t *testing.T) {
+	api := newFakeApplianceAPI()
+	srv := httptest.NewTLSServer(api)
+	defer srv.Close()
+	ac := newTestApplianceClient(srv, url.UserPassword("administrator@vsphere.local", "wrong"))
+	if _, err := collectApplianceConfig(ac); err == nil {
+		t.Error("collect with failed login: got nil err")
+	}
+	if len(api.unauthorized) != 0 || api.logouts != 0 {
+		t.Errorf("got %d requests without session and %d logouts after failed login, expected none",
+			len(api.unauthorized), api.logouts)
+	}
+}
+
+func TestApplianceConfigFlags(t *testing.T) {
+	enabled, disabled := true, false
+	now := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name     string
+		config   *VCApplianceConfig
+		expected []string
+	}{
+		{
+			name: "hardened",
+			config: &VCApplianceConfig{
+				Access: &vcApplianceAccess{SSH: &disabled, Shell: &vcApplianceShellAccess{}},
+				LocalAccounts: []*vcApplianceLocalAccount{
+					{Username: "root", Enabled: true, PasswordExpiresAt: "2023-11-30T00:00:00Z"},
+					{Username: "old", Enabled: false},
+				},
+				SyslogForwarding: []*vcApplianceSyslogForwarding{{Hostname: "siem", Port: 6514, Protocol: "TLS"}},
+				TimeSyncMode:     "NTP",
+				FirewallInbound:  []*vcApplianceFirewallRule{{Address: "10.0.0.0", Prefix: 8, Policy: "ACCEPT"}},
+				BackupSchedules:  map[string]*vcApplianceBackup{"default": {Location: "sftp://10.0.0.5/vcsa"}},
+			},
+			expected: nil,
+		},
+		{
+			name: "weakened",
+			config: &VCApplianceConfig{
+				Access: &vcApplianceAccess{SSH: &enabled, Shell: &vcApplianceShellAccess{Enabled: true}},
+				LocalAccounts: []*vcApplianceLocalAccount{
+					{Username: "root", Enabled: true, PasswordExpiresAt: "2023-08-02T00:00:00Z"},
+					{Username: "backdoor", Enabled: true},
+					{Username: "broken", Enabled: true, Error: "unexpected response status: 404 Not Found"},
+				},
+				SyslogForwarding: []*vcApplianceSyslogForwarding{{Hostname: "siem", Port: 514, Protocol: "UDP"}},
+				TimeSyncMode:     "DISABLED",
+				FirewallInbound:  []*vcApplianceFirewallRule{{Address: "0.0.0.0", Prefix: 0, Policy: "ACCEPT"}},
+				BackupSchedules:  map[string]*vcApplianceBackup{"default": {Location: "FTP://10.0.0.5/vcsa"}},
+			},
+			expected: []string{
+				"additional local account is enabled: backdoor",
+				"backup schedule default uses plaintext protocol: FTP://10.0.0.5/vcsa",
+				"bash shell access is enabled",
+				"firewall accepts inbound traffic from any address: 0.0.0.0",
+				"password of local account backdoor never expires",
+				"password of local account root has expired",
+				"ssh access is enabled",
+				"syslog forwarded without encryption to siem via UDP",
+				"time synchronization is disabled",
+			},
+		},
+		{
+			name:     "not configured",
+			config:   &VCApplianceConfig{},
+			expected: []string{"no backup schedule configured", "syslog forwarding is not configured"},
+		},
+		{
+			// missing result of failed endpoints must not be reported as not configured
+			name: "endpoints failed",
+			config: &VCApplianceConfig{Errors: map[string]string{
+				"/api/appliance/logging/forwarding":        "unexpected response status: 503",
+				"/api/appliance/recovery/backup/schedules": "unexpected response status: 503",
+			}},
+			expected: nil,
+		},
+	}
+	for _, c := range cases {
+		got := applianceConfigFlags(c.config, now)
+		if !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%s: got %v, expected %v", c.name, got, c.expected)
+		}
+	}
+}
@@ -2,13 +2,12 @@ package vsphere_api
 
 import (
 	"context"
-	"fmt"
 	log "github.com/sirupsen/logrus"
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/object"
-	ssometd "github.com/vmware/govmomi/ssoadmin/methods"
 	ssotypes "github.com/vmware/govmomi/ssoadmin/types"
 	"github.com/vmware/govmomi/vim25/types"
+	"time"
 )
 
 type vcUser struct {
@@ -21,19 +20,37 @@ type vcUser struct {
 	IsSolutionUser bool   `json:"is_solution_user"`
 	Kind           string `json:"kind"`
 	NickName       string `json:"nickname"` // firstname+lastname
+	// PasswordExpiresInDays is only available for person users in system domain
+	PasswordExpiresInDays   *int64     `json:"password_expires_in_days,omitempty"`
+	PasswordLastSetEstimate *time.Time `json:"password_last_set_estimate,omitempty"`
 }
 
 type VCBasicInfo struct {
-	IsVCenter             bool                   `json:"is_vcenter"`
-	ESXHostList           []string               `json:"esx_host_names,omitempty"`
-	ESXHostObjs           []*object.HostSystem   `json:"-"`
-	VCAuthoriRole         []*vcAuthorizationRole `json:"vc_authorization_roles,omitempty"`
-	VCAuthoriPerm         []*vcPermission        `json:"vc_authorization_permissions,omitempty"`
-	EventMaxAge           int                    `json:"event_max_age,omitempty"`
-	SSOPasswordPolicyDesc string                 `json:"sso_password_policy,omitempty"`
-	SSOIDPDesc            []*vcIdentityProvider  `json:"sso_idp,omitempty"`
-	SSOGroups             []*vcGroup             `json:"sso_groups,omitempty"`
-	SSOUsers              []*vcUser              `json:"sso_users,omitempty"`
+	IsVCenter           bool                          `json:"is_vcenter"`
+	ESXHostList         []string                      `json:"esx_host_names,omitempty"`
+	ESXHostObjs         []*object.HostSystem          `json:"-"`
+	ESXHosts            []*ESXHostBasicInfo           `json:"esx_hosts,omitempty"`
+	ESXHostStatus       []*esxHostCollectStatus       `json:"esx_host_status,omitempty"`
+	VCAuthoriRole       []*vcAuthorizationRole        `json:"vc_authorization_roles,omitempty"`
+	VCAuthoriPerm       []*vcPermission               `json:"vc_authorization_permissions,omitempty"`
+	EventMaxAge         int                           `json:"event_max_age,omitempty"`
+	SSOPolicies         *VCSSOPolicies                `json:"sso_policies,omitempty"`
+	SSOIDPDesc          []*vcIdentityProvider         `json:"sso_idp,omitempty"`
+	SSOGroups           []*vcGroup                    `json:"sso_groups,omitempty"`
+	SSOUsers            []*vcUser                     `json:"sso_users,omitempty"`
+	SSOGroupGraph       *VCSSOGroupGraph              `json:"sso_group_graph,omitempty"`
+	Alarms              *VCAlarmInventory             `json:"alarms,omitempty"`
+	AdvancedSettings    *VCAdvancedSettings           `json:"advanced_settings,omitempty"`
+	ScheduledTasks      map[string][]*vcScheduledTask `json:"scheduled_tasks,omitempty"`
+	Extensions          []*vcExtension                `json:"extensions,omitempty"`
+	DistributedSwitches []*vcDistributedSwitch        `json:"distributed_switches,omitempty"`
+	Clusters            []*vcCluster                  `json:"clusters,omitempty"`
+	OtherHostProfiles   []*vcHostProfile              `json:"other_host_profiles,omitempty"`
+	Certificates        []*vcCertificate              `json:"certificates,omitempty"`
+	RoleAnalysis        *VCRoleAnalysis               `json:"role_analysis,omitempty"`
+	Appliance           *VCApplianceConfig            `json:"appliance,omitempty"`
+	// ssoMembership is direct members of expanded SSO groups from ListSSOGroupMembers, by normalized group name
+	ssoMembership map[string]*ssoGroupMembers
 }
 
 type vcIdentityProviders struct {
@@ -110,12 +127,16 @@ type vcPermission struct {
 	IsGroup   bool   `json:"is_group"`
 	RoleId    int32  `json:"role_id"`
 	Propagate bool   `json:"propagate"`
+	// entityRef is used to locate entity in inventory tree by effective permission resolver
+	entityRef types.ManagedObjectReference
 }
 
 type vcGroup struct {
-	Name    string `json:"name"`
-	Alias   string `json:"alias"`
-	Details string `json:"details"`
+	Name         string          `json:"name"`
+	Alias        string          `json:"alias"`
+	Details      string          `json:"details"`
+	Members      *vcGroupMembers `json:"members,omitempty"`
+	MembersError string          `json:"members_error,omitempty"`
 }
 
 type vcAuthorizationRole struct {
@@ -189,6 +210,7 @@ func fromVInternalPermissionSetToOutPerm(r1 types.Permission) (*vcPermission, er
 		IsGroup:   r1.Group,
 		RoleId:    r1.RoleId,
 		Propagate: r1.Propagate,
+		entityRef: r1.Entity.Reference(),
 	}
 	return res, nil
 }
@@ -293,49 +315,5 @@ func (vsc *vSphereClient) ListAllUsers(vcbi *VCBasicInfo) error {
 		resUsers = append(resUsers, pu)
 	}
 	vcbi.SSOUsers = resUsers
-	// list local password policies
-	lppreq := &ssotypes.GetLocalPasswordPolicy{
-		This: ssocli.ServiceContent.PasswordPolicyService,
-	}
-	lppi := &lppInfo{}
-	retV, err := ssometd.GetLocalPasswordPolicy(tmpCtx, ssocli, lppreq)
-	if err != nil {
-		log.Errorln("get local password policy err.")
-		return err
-	}
-	lppi.LocalPasswordPolicy = retV.Returnval
-	log.Debugln("executed: show local password policy in ListAllUsers")
-	vcbi.SSOPasswordPolicyDesc = lppi.String()
 	return nil
 }
-
-type lppInfo struct {
-	LocalPasswordPolicy ssotypes.AdminPasswordPolicy
-}
-
-func (r *lppInfo) String() string {
-	return fmt.Sprintf(
-		"Description: %s,"+
-			"MinLength: %d,"+
-			"MaxLength: %d,"+
-			"MinAlphabeticCount: %d,"+
-			"MinUppercaseCount: %d,"+
-			"MinLowercaseCount: %d,"+
-			"MinNumericCount: %d,"+
-			"MinSpecialCharCount: %d,"+
-			"MaxIdenticalAdjacentCharacters: %d,"+
-			"ProhibitedPreviousPasswordsCount: %d,"+
-			"PasswordLifetimeDays: %d.",
-		r.LocalPasswordPolicy.Description,
-		r.LocalPasswordPolicy.PasswordFormat.LengthRestriction.MinLength,
-		r.LocalPasswordPolicy.PasswordFormat.LengthRestriction.MaxLength,
-		r.LocalPasswordPolicy.PasswordFormat.AlphabeticRestriction.MinAlphabeticCount,
-		r.LocalPasswordPolicy.PasswordFormat.AlphabeticRestriction.MinUppercaseCount,
-		r.LocalPasswordPolicy.PasswordFormat.AlphabeticRestriction.MinLowercaseCount,
-		r.LocalPasswordPolicy.PasswordFormat.MinNumericCount,
-		r.LocalPasswordPolicy.PasswordFormat.MinSpecialCharCount,
-		r.LocalPasswordPolicy.PasswordFormat.MaxIdenticalAdjacentCharacters,
-		r.LocalPasswordPolicy.ProhibitedPreviousPasswordsCount,
-		r.LocalPasswordPolicy.PasswordLifetimeDays,
-	)
-}
@@ -0,0 +1,289 @@
+package vsphere_api
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/lookup"
+	lookuptypes "github.com/vmware/govmomi/lookup/types"
+	ssometd "github.com/vmware/govmomi/ssoadmin/methods"
+	ssotypes "github.com/vmware/govmomi/ssoadmin/types"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNoCertificateFound   = errors.New("no certificate found in input")
+	ErrNoTLSPeerCertificate = errors.New("server connection is not tls, no peer certificate")
+)
+
+var (
+	// certRecentlyIssuedDays is used to flag trust anchors and signing certificates issued recently
+	certRecentlyIssuedDays = 90
+)
+
+type vcCertificate struct {
+	// Source is one of: vcenter_tls, sso_trusted_root, sts_signing_chain, sso_issuer, solution_user, lookup_service,
+	// ldaps_trusted, ldaps_server_probe
+	Source             string    `json:"source"`
+	Owner              string    `json:"owner,omitempty"`
+	Subject            string    `json:"subject"`
+	Issuer             string    `json:"issuer"`
+	SerialNumber       string    `json:"serial_number"`
+	NotBefore          time.Time `json:"not_before"`
+	NotAfter           time.Time `json:"not_after"`
+	KeyType            string    `json:"key_type"`
+	KeyBits            int       `json:"key_bits,omitempty"`
+	SignatureAlgorithm string    `json:"signature_algorithm"`
+	IsCA               bool      `json:"is_ca"`
+	SelfSigned         bool      `json:"self_signed"`
+	ThumbprintSHA1     string    `json:"thumbprint_sha_1"`
+	ThumbprintSHA256   string    `json:"thumbprint_sha_256"`
+	Flags              []string  `json:"flags,omitempty"`
+}
+
+// ListVCCertificates collect certificate of vCenter itself, SSO trusted roots, STS signing chains, solution user
+// certificates and lookup service endpoint trust, flag suspicious trust anchors and signing certificates.
+func (vsc *vSphereClient) ListVCCertificates(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	res := make([]*vcCertificate, 0)
+	// vcenter tls chain, request goes through default transport, so proxy setting is respected
+	tlsCerts, err := vsc.retrieveTLSPeerCertificates()
+	if err != nil {
+		log.Errorln("retrieve vcenter tls certificate, err: ", err)
+	}
+	for _, c := range tlsCerts {
+		res = append(res, convertX509Cert2External(c, "vcenter_tls", vsc.vmwSoapClient.URL().Host))
+	}
+	// lookup service does not require authentication
+	lsCli, err := lookup.NewClient(tmpCtx, vsc.vmwSoapClient)
+	if err != nil {
+		log.Errorln("create lookup service client, err: ", err)
+	} else {
+		// nil filter is rejected by lookup service, empty filter matches every registration
+		regs, err := lsCli.List(tmpCtx, &lookuptypes.LookupServiceRegistrationFilter{})
+		if err != nil {
+			log.Errorln("list lookup service registrations, err: ", err)
+		}
+		// same endpoint certificate is registered for many services, deduplicate by owner and thumbprint
+		seenCerts := make(map[string]bool)
+		for _, reg := range regs {
+			owner := reg.ServiceType.Product + ":" + reg.ServiceType.Type + " (" + reg.OwnerId + ")"
+			for _, ep := range reg.ServiceEndpoints {
+				for _, st := range ep.SslTrust {
+					vcc, err := parseCertString2External(st, "lookup_service", owner)
+					if err != nil {
+						log.Debugln("parse lookup service ssl trust, err: ", err)
+						continue
+					}
+					if seenCerts[owner+vcc.ThumbprintSHA256] {
+						continue
+					}
+					seenCerts[owner+vcc.ThumbprintSHA256] = true
+					res = append(res, vcc)
+				}
+			}
+		}
+	}
+	// sso admin
+	ssocli, err := vsc.Login2SSOMgmt()
+	if err != nil || ssocli == nil {
+		log.Errorln("cannot create ssoadmin client, err:", err)
+		vcbi.Certificates = res
+		return err
+	}
+	cfgMgmt := ssocli.ServiceContent.ConfigurationManagementService
+	trustedResp, err := ssometd.GetTrustedCertificates(tmpCtx, ssocli,
+		&ssotypes.GetTrustedCertificates{This: cfgMgmt})
+	if err != nil {
+		log.Errorln("get sso trusted certificates, err: ", err)
+	} else {
+		res = append(res, parseCertStrings2External(trustedResp.Returnval, "sso_trusted_root", "")...)
+	}
+	chainsResp, err := ssometd.GetKnownCertificateChains(tmpCtx, ssocli,
+		&ssotypes.GetKnownCertificateChains{This: cfgMgmt})
+	if err != nil {
+		log.Errorln("get sts signing certificate chains, err: ", err)
+	} else {
+		for i, chain := range chainsResp.Returnval {
+			res = append(res, parseCertStrings2External(chain.Certificates, "sts_signing_chain",
+				fmt.Sprintf("chain-%d", i))...)
+		}
+	}
+	issuersResp, err := ssometd.GetIssuersCertificates(tmpCtx, ssocli,
+		&ssotypes.GetIssuersCertificates{This: cfgMgmt})
+	if err != nil {
+		log.Errorln("get sso issuers certificates, err: ", err)
+	} else {
+		res = append(res, parseCertStrings2External(issuersResp.Returnval, "sso_issuer", "")...)
+	}
+	soUsers, err := ssocli.FindSolutionUsers(tmpCtx, "")
+	if err != nil {
+		log.Errorln("find solution users, err: ", err)
+	}
+	for _, v := range soUsers {
+		res = append(res, parseCertStrings2External([]string{v.Details.Certificate}, "solution_user",
+			v.Id.Name+"@"+v.Id.Domain)...)
+	}
+	flagVCCertificates(res)
+	vcbi.Certificates = res
+	log.Infof("%d certificates retrieved.", len(res))
+	return nil
+}
+
+func (vsc *vSphereClient) retrieveTLSPeerCertificates() ([]*x509.Certificate, error) {
+	u := vsc.vmwSoapClient.URL()
+	req, err := http.NewRequest(http.MethodHead, u.Scheme+"://"+u.Host+"/", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.TLS == nil {
+		return nil, ErrNoTLSPeerCertificate
+	}
+	return resp.TLS.PeerCertificates, nil
+}
+
+// flagVCCertificates flag expired and weak certificates, recently issued trust anchors and signing certificates,
+// and STS signing chains other than the active one, which may indicate Golden SAML style abuse. Old chain is kept
+// after normal STS certificate replacement as well, so chain is only flagged when active signer is known.
+func flagVCCertificates(certs []*vcCertificate) {
+	now := time.Now()
+	recentSince := now.AddDate(0, 0, -certRecentlyIssuedDays)
+	// sso_issuer certificates are the ones STS currently signs tokens with
+	issuerThumbprints := make(map[string]bool)
+	for _, c := range certs {
+		if c.Source == "sso_issuer" {
+			issuerThumbprints[c.ThumbprintSHA256] = true
+		}
+	}
+	// chains issued by VMCA share the same root, so only signing certificate itself tells which chain is active
+	activeChains := make(map[string]bool)
+	for _, c := range certs {
+		if c.Source == "sts_signing_chain" && !c.IsCA && issuerThumbprints[c.ThumbprintSHA256] {
+			activeChains[c.Owner] = true
+		}
+	}
+	for _, c := range certs {
+		if now.After(c.NotAfter) {
+			c.Flags = append(c.Flags, "expired")
+		}
+		if strings.HasPrefix(c.KeyType, "RSA") && c.KeyBits < 2048 {
+			c.Flags = append(c.Flags, "weak key")
+		}
+		switch c.Source {
+		case "sso_trusted_root":
+			if !c.IsCA {
+				c.Flags = append(c.Flags, "trusted certificate is not a CA")
+			}
+			if c.NotBefore.After(recentSince) {
+				c.Flags = append(c.Flags, "trusted root issued recently")
+			}
+		case "sts_signing_chain":
+			if c.NotBefore.After(recentSince) {
+				c.Flags = append(c.Flags, "signing certificate issued recently")
+			}
+			if len(activeChains) != 0 && !activeChains[c.Owner] {
+				c.Flags = append(c.Flags, "sts signing chain is not the active one")
+			}
+		}
+	}
+}
+
+func parseCertStrings2External(certStrs []string, source string, owner string) []*vcCertificate {
+	res := make([]*vcCertificate, 0, len(certStrs))
+	for _, s := range certStrs {
+		vcc, err := parseCertString2External(s, source, owner)
+		if err != nil {
+			log.Errorln("parse certificate from ", source, ", err: ", err)
+			continue
+		}
+		res = append(res, vcc)
+	}
+	return res
+}
+
+// parseCertString2External accepts both PEM and base64 encoded DER certificate.
+func parseCertString2External(s string, source string, owner string) (*vcCertificate, error) {
+	s = strings.TrimSpace(s)
+	var der []byte
+	if strings.Contains(s, "-----BEGIN") {
+		blk, _ := pem.Decode([]byte(s))
+		if blk == nil {
+			return nil, ErrNoCertificateFound
+		}
+		der = blk.Bytes
+	} else {
+		var err error
+		der, err = base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return convertX509Cert2External(cert, source, owner), nil
+}
+
+func convertX509Cert2External(cert *x509.Certificate, source string, owner string) *vcCertificate {
+	sha1Sum := sha1.Sum(cert.Raw)
+	sha256Sum := sha256.Sum256(cert.Raw)
+	// CheckSignatureFrom requires parent to be a CA, self-signed leaf must be verified with its own key directly
+	selfSigned := cert.Subject.String() == cert.Issuer.String() &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+	res := &vcCertificate{
+		Source:             source,
+		Owner:              owner,
+		Subject:            cert.Subject.String(),
+		Issuer:             cert.Issuer.String(),
+		SerialNumber:       fmt.Sprintf("%X", cert.SerialNumber),
+		NotBefore:          cert.NotBefore,
+		NotAfter:           cert.NotAfter,
+		SignatureAlgorithm: cert.SignatureAlgorithm.String(),
+		IsCA:               cert.IsCA,
+		SelfSigned:         selfSigned,
+		ThumbprintSHA1:     certFingerprint(sha1Sum[:]),
+		ThumbprintSHA256:   certFingerprint(sha256Sum[:]),
+	}
+	switch pk := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		res.KeyBits = pk.N.BitLen()
+		res.KeyType = fmt.Sprintf("RSA-%d", res.KeyBits)
+	case *ecdsa.PublicKey:
+		res.KeyBits = pk.Curve.Params().BitSize
+		res.KeyType = "ECDSA-" + pk.Curve.Params().Name
+	case ed25519.PublicKey:
+		res.KeyType = "Ed25519"
+	default:
+		res.KeyType = cert.PublicKeyAlgorithm.String()
+	}
+	return res
+}
+
+// certFingerprint format digest as colon separated upper-case hex, same as vSphere Client shows
+func certFingerprint(digest []byte) string {
+	parts := make([]string, 0, len(digest))
+	for _, b := range digest {
+		parts = append(parts, fmt.Sprintf("%02X", b))
+	}
+	return strings.Join(parts, ":")
+}
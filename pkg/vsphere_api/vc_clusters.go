@@ -0,0 +1,330 @@
+package vsphere_api
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/methods"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"reflect"
+	"time"
+)
+
+type vcCluster struct {
+	Name          string            `json:"name"`
+	InventoryPath string            `json:"inventory_path"`
+	MoRef         string            `json:"moref"`
+	Hosts         []string          `json:"hosts,omitempty"`
+	HA            *vcClusterHA      `json:"ha,omitempty"`
+	DRS           *vcClusterDRS     `json:"drs,omitempty"`
+	Rules         []*vcClusterRule  `json:"rules,omitempty"`
+	Groups        []*vcClusterGroup `json:"groups,omitempty"`
+	EVCMode       string            `json:"evc_mode,omitempty"`
+	HostProfiles  []*vcHostProfile  `json:"host_profiles,omitempty"`
+}
+
+type vcClusterHA struct {
+	Enabled                 bool              `json:"enabled"`
+	HostMonitoring          string            `json:"host_monitoring,omitempty"`
+	VmMonitoring            string            `json:"vm_monitoring,omitempty"`
+	AdmissionControlEnabled bool              `json:"admission_control_enabled"`
+	AdmissionControlPolicy  string            `json:"admission_control_policy,omitempty"`
+	FailoverLevel           int32             `json:"failover_level,omitempty"`
+	HeartbeatDatastores     []string          `json:"heartbeat_datastores,omitempty"`
+	Options                 map[string]string `json:"options,omitempty"`
+}
+
+type vcClusterDRS struct {
+	Enabled                   bool              `json:"enabled"`
+	DefaultVmBehavior         string            `json:"default_vm_behavior,omitempty"`
+	EnableVmBehaviorOverrides bool              `json:"enable_vm_behavior_overrides"`
+	VmotionRate               int32             `json:"vmotion_rate,omitempty"`
+	Options                   map[string]string `json:"options,omitempty"`
+}
+
+type vcClusterRule struct {
+	Name                string   `json:"name"`
+	Type                string   `json:"type"`
+	Enabled             bool     `json:"enabled"`
+	Mandatory           bool     `json:"mandatory"`
+	UserCreated         bool     `json:"user_created"`
+	InCompliance        *bool    `json:"in_compliance,omitempty"`
+	Status              string   `json:"status,omitempty"`
+	Vms                 []string `json:"vms,omitempty"`
+	VmGroup             string   `json:"vm_group,omitempty"`
+	AffineHostGroup     string   `json:"affine_host_group,omitempty"`
+	AntiAffineHostGroup string   `json:"anti_affine_host_group,omitempty"`
+	DependsOnVmGroup    string   `json:"depends_on_vm_group,omitempty"`
+}
+
+type vcClusterGroup struct {
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	Members []string `json:"members,omitempty"`
+}
+
+type vcHostProfile struct {
+	Name             string                 `json:"name"`
+	MoRef            string                 `json:"moref"`
+	Description      string                 `json:"description,omitempty"`
+	CreatedTime      time.Time              `json:"created_time"`
+	ModifiedTime     time.Time              `json:"modified_time"`
+	ReferenceHost    string                 `json:"reference_host,omitempty"`
+	AttachedEntities []string               `json:"attached_entities,omitempty"`
+	ComplianceStatus string                 `json:"compliance_status,omitempty"`
+	Compliance       []*vcProfileCompliance `json:"compliance,omitempty"`
+	// entities is used for matching profile to cluster
+	entities []types.ManagedObjectReference `json:"-"`
+}
+
+type vcProfileCompliance struct {
+	Entity    string     `json:"entity"`
+	Status    string     `json:"status"`
+	CheckTime *time.Time `json:"check_time,omitempty"`
+	Failures  []string   `json:"failures,omitempty"`
+}
+
+// ListClusters collect cluster membership, HA/DRS settings, DRS rules and groups, EVC mode, and attach host profiles
+// with compliance results to the cluster they are applied to (directly or via member hosts).
+func (vsc *vSphereClient) ListClusters(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(tmpCtx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"ClusterComputeResource"}, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpCtx)
+	}()
+	var clusters []mo.ClusterComputeResource
+	err = ctnrView.Retrieve(tmpCtx, []string{"ClusterComputeResource"},
+		[]string{"name", "host", "configurationEx", "summary"}, &clusters)
+	if err != nil {
+		return err
+	}
+	pathCache := make(invtPathCache)
+	// host profiles are optional, failure should not stop cluster collection
+	hostProfiles, err := vsc.listHostProfiles(pathCache)
+	if err != nil {
+		log.Errorln("list host profiles, err: ", err)
+	}
+	matchedProfiles := make(map[*vcHostProfile]bool)
+	vcbi.Clusters = make([]*vcCluster, 0, len(clusters))
+	for i := range clusters {
+		vcc := convertCluster2External(&clusters[i], pathCache)
+		// entities belongs to this cluster: cluster itself and member hosts
+		clusterEntities := map[types.ManagedObjectReference]bool{clusters[i].Self: true}
+		for _, h := range clusters[i].Host {
+			clusterEntities[h] = true
+		}
+		for _, hp := range hostProfiles {
+			for _, ent := range hp.entities {
+				if clusterEntities[ent] {
+					vcc.HostProfiles = append(vcc.HostProfiles, hp)
+					matchedProfiles[hp] = true
+					break
+				}
+			}
+		}
+		vcbi.Clusters = append(vcbi.Clusters, vcc)
+	}
+	// profiles attached to standalone host or not attached at all
+	for _, hp := range hostProfiles {
+		if !matchedProfiles[hp] {
+			vcbi.OtherHostProfiles = append(vcbi.OtherHostProfiles, hp)
+		}
+	}
+	log.Infof("%d clusters, %d host profiles retrieved.", len(clusters), len(hostProfiles))
+	return nil
+}
+
+func convertCluster2External(cl *mo.ClusterComputeResource, pathCache invtPathCache) *vcCluster {
+	res := &vcCluster{
+		Name:          cl.Name,
+		InventoryPath: pathCache.Resolve(cl.Self),
+		MoRef:         cl.Self.Value,
+	}
+	for _, h := range cl.Host {
+		res.Hosts = append(res.Hosts, pathCache.Resolve(h))
+	}
+	if summ, ok := cl.Summary.(*types.ClusterComputeResourceSummary); ok {
+		res.EVCMode = summ.CurrentEVCModeKey
+	}
+	cfg, ok := cl.ConfigurationEx.(*types.ClusterConfigInfoEx)
+	if !ok {
+		return res
+	}
+	das := &cfg.DasConfig
+	res.HA = &vcClusterHA{
+		Enabled:                 isTrue(das.Enabled),
+		HostMonitoring:          das.HostMonitoring,
+		VmMonitoring:            das.VmMonitoring,
+		AdmissionControlEnabled: isTrue(das.AdmissionControlEnabled),
+		FailoverLevel:           das.FailoverLevel,
+		Options:                 optionValues2Map(das.Option),
+	}
+	if das.AdmissionControlPolicy != nil {
+		res.HA.AdmissionControlPolicy = reflect.TypeOf(das.AdmissionControlPolicy).Elem().Name()
+	}
+	for _, ds := range das.HeartbeatDatastore {
+		res.HA.HeartbeatDatastores = append(res.HA.HeartbeatDatastores, pathCache.Resolve(ds))
+	}
+	drs := &cfg.DrsConfig
+	res.DRS = &vcClusterDRS{
+		Enabled:                   isTrue(drs.Enabled),
+		DefaultVmBehavior:         string(drs.DefaultVmBehavior),
+		EnableVmBehaviorOverrides: isTrue(drs.EnableVmBehaviorOverrides),
+		VmotionRate:               drs.VmotionRate,
+		Options:                   optionValues2Map(drs.Option),
+	}
+	for _, br := range cfg.Rule {
+		res.Rules = append(res.Rules, convertClusterRule2External(br, pathCache))
+	}
+	for _, bg := range cfg.Group {
+		g := bg.GetClusterGroupInfo()
+		vcg := &vcClusterGroup{
+			Name: g.Name,
+			Type: reflect.TypeOf(bg).Elem().Name(),
+		}
+		switch grp := bg.(type) {
+		case *types.ClusterVmGroup:
+			for _, vm := range grp.Vm {
+				vcg.Members = append(vcg.Members, pathCache.Resolve(vm))
+			}
+		case *types.ClusterHostGroup:
+			for _, h := range grp.Host {
+				vcg.Members = append(vcg.Members, pathCache.Resolve(h))
+			}
+		}
+		res.Groups = append(res.Groups, vcg)
+	}
+	return res
+}
+
+func convertClusterRule2External(br types.BaseClusterRuleInfo, pathCache invtPathCache) *vcClusterRule {
+	r := br.GetClusterRuleInfo()
+	res := &vcClusterRule{
+		Name:         r.Name,
+		Type:         reflect.TypeOf(br).Elem().Name(),
+		Enabled:      isTrue(r.Enabled),
+		Mandatory:    isTrue(r.Mandatory),
+		UserCreated:  isTrue(r.UserCreated),
+		InCompliance: r.InCompliance,
+		Status:       string(r.Status),
+	}
+	switch rule := br.(type) {
+	case *types.ClusterAffinityRuleSpec:
+		for _, vm := range rule.Vm {
+			res.Vms = append(res.Vms, pathCache.Resolve(vm))
+		}
+	case *types.ClusterAntiAffinityRuleSpec:
+		for _, vm := range rule.Vm {
+			res.Vms = append(res.Vms, pathCache.Resolve(vm))
+		}
+	case *types.ClusterVmHostRuleInfo:
+		res.VmGroup = rule.VmGroupName
+		res.AffineHostGroup = rule.AffineHostGroupName
+		res.AntiAffineHostGroup = rule.AntiAffineHostGroupName
+	case *types.ClusterDependencyRuleInfo:
+		res.VmGroup = rule.VmGroup
+		res.DependsOnVmGroup = rule.DependsOnVmGroup
+	}
+	return res
+}
+
+// listHostProfiles retrieve all host profiles from HostProfileManager, query compliance status of attached entities.
+func (vsc *vSphereClient) listHostProfiles(pathCache invtPathCache) ([]*vcHostProfile, error) {
+	if vsc.vmwSoapClient.ServiceContent.HostProfileManager == nil {
+		return nil, nil
+	}
+	tmpCtx := context.Background()
+	coll := property.DefaultCollector(vsc.vmwSoapClient)
+	var hpMgr mo.ProfileManager
+	err := coll.RetrieveOne(tmpCtx, *vsc.vmwSoapClient.ServiceContent.HostProfileManager, []string{"profile"}, &hpMgr)
+	if err != nil {
+		return nil, err
+	}
+	if len(hpMgr.Profile) == 0 {
+		return nil, nil
+	}
+	var profiles []mo.HostProfile
+	err = coll.Retrieve(tmpCtx, hpMgr.Profile, []string{"name", "createdTime", "modifiedTime", "entity",
+		"complianceStatus", "referenceHost", "config"}, &profiles)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*vcHostProfile, 0, len(profiles))
+	for i := range profiles {
+		hp := &vcHostProfile{
+			Name:             profiles[i].Name,
+			MoRef:            profiles[i].Self.Value,
+			CreatedTime:      profiles[i].CreatedTime,
+			ModifiedTime:     profiles[i].ModifiedTime,
+			ComplianceStatus: profiles[i].ComplianceStatus,
+			entities:         profiles[i].Entity,
+		}
+		if profiles[i].Config != nil {
+			hp.Description = profiles[i].Config.GetProfileConfigInfo().Annotation
+		}
+		if profiles[i].ReferenceHost != nil {
+			hp.ReferenceHost = pathCache.Resolve(*profiles[i].ReferenceHost)
+		}
+		for _, ent := range profiles[i].Entity {
+			hp.AttachedEntities = append(hp.AttachedEntities, pathCache.Resolve(ent))
+		}
+		if len(profiles[i].Entity) != 0 && vsc.vmwSoapClient.ServiceContent.ComplianceManager != nil {
+			hp.Compliance, err = vsc.queryProfileCompliance(profiles[i].Self, profiles[i].Entity, pathCache)
+			if err != nil {
+				log.Errorln("query compliance of host profile ", hp.Name, ", err: ", err)
+			}
+		}
+		res = append(res, hp)
+	}
+	return res, nil
+}
+
+func (vsc *vSphereClient) queryProfileCompliance(profile types.ManagedObjectReference,
+	entities []types.ManagedObjectReference, pathCache invtPathCache) ([]*vcProfileCompliance, error) {
+	resp, err := methods.QueryComplianceStatus(context.Background(), vsc.vmwSoapClient, &types.QueryComplianceStatus{
+		This:    *vsc.vmwSoapClient.ServiceContent.ComplianceManager,
+		Profile: []types.ManagedObjectReference{profile},
+		Entity:  entities,
+	})
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*vcProfileCompliance, 0, len(resp.Returnval))
+	for _, cr := range resp.Returnval {
+		pc := &vcProfileCompliance{
+			Status:    cr.ComplianceStatus,
+			CheckTime: cr.CheckTime,
+		}
+		if cr.Entity != nil {
+			pc.Entity = pathCache.Resolve(*cr.Entity)
+		}
+		for _, f := range cr.Failure {
+			pc.Failures = append(pc.Failures, fmt.Sprintf("%s: %s", f.FailureType, f.Message.Message))
+		}
+		res = append(res, pc)
+	}
+	return res, nil
+}
+
+func optionValues2Map(opts []types.BaseOptionValue) map[string]string {
+	if len(opts) == 0 {
+		return nil
+	}
+	res := make(map[string]string, len(opts))
+	for _, bov := range opts {
+		ov := bov.GetOptionValue()
+		res[ov.Key] = fmt.Sprintf("%v", ov.Value)
+	}
+	return res
+}
@@ -0,0 +1,346 @@
+package vsphere_api
+
+import (
+	"context"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"strings"
+	"time"
+)
+
+type vcDistributedSwitch struct {
+	Name           string                `json:"name"`
+	InventoryPath  string                `json:"inventory_path"`
+	MoRef          string                `json:"moref"`
+	UUID           string                `json:"uuid"`
+	Vendor         string                `json:"vendor,omitempty"`
+	Version        string                `json:"version,omitempty"`
+	Description    string                `json:"description,omitempty"`
+	CreateTime     time.Time             `json:"create_time"`
+	MaxMtu         int32                 `json:"max_mtu,omitempty"`
+	Uplinks        []string              `json:"uplinks,omitempty"`
+	HostMembers    []*vcDVSHostMember    `json:"host_members,omitempty"`
+	DefaultPolicy  *vcDVPortPolicy       `json:"default_port_policy,omitempty"`
+	PortGroups     []*vcDVPortgroup      `json:"port_groups,omitempty"`
+	Ipfix          *vcDVSIpfix           `json:"ipfix,omitempty"`
+	MirrorSessions []*vcDVSMirrorSession `json:"mirror_sessions,omitempty"`
+	Flags          []string              `json:"flags,omitempty"`
+}
+
+type vcDVSHostMember struct {
+	Host   string   `json:"host"`
+	Status string   `json:"status"`
+	Pnics  []string `json:"pnics,omitempty"`
+}
+
+type vcDVPortgroup struct {
+	Name     string          `json:"name"`
+	Key      string          `json:"key"`
+	MoRef    string          `json:"moref"`
+	Type     string          `json:"type"`
+	NumPorts int32           `json:"num_ports"`
+	IsUplink bool            `json:"is_uplink"`
+	Policy   *vcDVPortPolicy `json:"policy,omitempty"`
+	Flags    []string        `json:"flags,omitempty"`
+}
+
+type vcDVPortPolicy struct {
+	VlanType         string   `json:"vlan_type,omitempty"`
+	Vlan             string   `json:"vlan,omitempty"`
+	AllowPromiscuous *bool    `json:"allow_promiscuous,omitempty"`
+	MacChanges       *bool    `json:"mac_changes,omitempty"`
+	ForgedTransmits  *bool    `json:"forged_transmits,omitempty"`
+	IpfixEnabled     *bool    `json:"ipfix_enabled,omitempty"`
+	Blocked          *bool    `json:"blocked,omitempty"`
+	ActiveUplinks    []string `json:"active_uplinks,omitempty"`
+	StandbyUplinks   []string `json:"standby_uplinks,omitempty"`
+}
+
+type vcDVSIpfix struct {
+	CollectorIpAddress  string `json:"collector_ip_address,omitempty"`
+	CollectorPort       int32  `json:"collector_port,omitempty"`
+	ObservationDomainId int64  `json:"observation_domain_id,omitempty"`
+	SamplingRate        int32  `json:"sampling_rate"`
+	InternalFlowsOnly   bool   `json:"internal_flows_only"`
+}
+
+type vcDVSMirrorSession struct {
+	Key                  string `json:"key"`
+	Name                 string `json:"name"`
+	Description          string `json:"description,omitempty"`
+	Enabled              bool   `json:"enabled"`
+	SessionType          string `json:"session_type,omitempty"`
+	SourceTransmitted    string `json:"source_transmitted,omitempty"`
+	SourceReceived       string `json:"source_received,omitempty"`
+	Destination          string `json:"destination,omitempty"`
+	EncapsulationVlanId  int32  `json:"encapsulation_vlan_id,omitempty"`
+	EncapType            string `json:"encap_type,omitempty"`
+	ErspanId             int32  `json:"erspan_id,omitempty"`
+	StripOriginalVlan    bool   `json:"strip_original_vlan"`
+	NormalTrafficAllowed bool   `json:"normal_traffic_allowed"`
+	MirroredPacketLength int32  `json:"mirrored_packet_length,omitempty"`
+	SamplingRate         int32  `json:"sampling_rate,omitempty"`
+}
+
+// ListDistributedSwitches collect every distributed virtual switch with its port groups, security policies, VLANs,
+// uplinks, IPFIX (NetFlow) target and port mirroring sessions. Risky settings are flagged.
+func (vsc *vSphereClient) ListDistributedSwitches(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(tmpCtx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"DistributedVirtualSwitch", "DistributedVirtualPortgroup"}, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpCtx)
+	}()
+	var dvSwitches []mo.DistributedVirtualSwitch
+	err = ctnrView.Retrieve(tmpCtx, []string{"DistributedVirtualSwitch"}, []string{"name", "config"}, &dvSwitches)
+	if err != nil {
+		return err
+	}
+	var dvPortgroups []mo.DistributedVirtualPortgroup
+	err = ctnrView.Retrieve(tmpCtx, []string{"DistributedVirtualPortgroup"}, []string{"name", "config"}, &dvPortgroups)
+	if err != nil {
+		return err
+	}
+	pathCache := make(invtPathCache)
+	vcbi.DistributedSwitches = make([]*vcDistributedSwitch, 0, len(dvSwitches))
+	dvsByRef := make(map[types.ManagedObjectReference]*vcDistributedSwitch, len(dvSwitches))
+	// config.uplink of port group is not set on older version, use uplinkPortgroup of switch instead
+	uplinkPGs := make(map[types.ManagedObjectReference]bool)
+	for i := range dvSwitches {
+		vds := convertDVSwitch2External(&dvSwitches[i], pathCache)
+		dvsByRef[dvSwitches[i].Self] = vds
+		vcbi.DistributedSwitches = append(vcbi.DistributedSwitches, vds)
+		if dvSwitches[i].Config != nil {
+			for _, ref := range dvSwitches[i].Config.GetDVSConfigInfo().UplinkPortgroup {
+				uplinkPGs[ref] = true
+			}
+		}
+	}
+	for i := range dvPortgroups {
+		pgCfg := &dvPortgroups[i].Config
+		if pgCfg.DistributedVirtualSwitch == nil {
+			continue
+		}
+		vds, ok := dvsByRef[*pgCfg.DistributedVirtualSwitch]
+		if !ok {
+			continue
+		}
+		vdpg := &vcDVPortgroup{
+			Name:     dvPortgroups[i].Name,
+			Key:      pgCfg.Key,
+			MoRef:    dvPortgroups[i].Self.Value,
+			Type:     pgCfg.Type,
+			NumPorts: pgCfg.NumPorts,
+			IsUplink: isTrue(pgCfg.Uplink) || uplinkPGs[dvPortgroups[i].Self],
+			Policy:   convertDVPortSetting2External(pgCfg.DefaultPortConfig),
+		}
+		vdpg.Flags = dvPortPolicyFlags(vdpg.Policy, vdpg.IsUplink)
+		if vdpg.Policy != nil && isTrue(vdpg.Policy.IpfixEnabled) && vds.Ipfix != nil &&
+			vds.Ipfix.CollectorIpAddress != "" {
+			vdpg.Flags = append(vdpg.Flags, "traffic flow exported to "+vds.Ipfix.CollectorIpAddress)
+		}
+		for _, f := range vdpg.Flags {
+			vds.Flags = append(vds.Flags, "portgroup "+vdpg.Name+": "+f)
+		}
+		vds.PortGroups = append(vds.PortGroups, vdpg)
+	}
+	flaggedCnt := 0
+	for _, vds := range vcbi.DistributedSwitches {
+		if len(vds.Flags) != 0 {
+			flaggedCnt++
+			log.Warnf("Distributed switch flagged: %s, %s", vds.Name, strings.Join(vds.Flags, "; "))
+		}
+	}
+	log.Infof("%d distributed switches, %d port groups retrieved, %d switches flagged.", len(dvSwitches),
+		len(dvPortgroups), flaggedCnt)
+	return nil
+}
+
+func convertDVSwitch2External(dvs *mo.DistributedVirtualSwitch, pathCache invtPathCache) *vcDistributedSwitch {
+	res := &vcDistributedSwitch{
+		Name:          dvs.Name,
+		InventoryPath: pathCache.Resolve(dvs.Self),
+		MoRef:         dvs.Self.Value,
+	}
+	if dvs.Config == nil {
+		return res
+	}
+	cfg := dvs.Config.GetDVSConfigInfo()
+	res.UUID = cfg.Uuid
+	res.Vendor = cfg.ProductInfo.Vendor
+	res.Version = cfg.ProductInfo.Version
+	res.Description = cfg.Description
+	res.CreateTime = cfg.CreateTime
+	if up, ok := cfg.UplinkPortPolicy.(*types.DVSNameArrayUplinkPortPolicy); ok {
+		res.Uplinks = up.UplinkPortName
+	}
+	for _, hm := range cfg.Host {
+		vhm := &vcDVSHostMember{Status: hm.Status}
+		if hm.Config.Host != nil {
+			vhm.Host = pathCache.Resolve(*hm.Config.Host)
+		}
+		if pb, ok := hm.Config.Backing.(*types.DistributedVirtualSwitchHostMemberPnicBacking); ok {
+			for _, ps := range pb.PnicSpec {
+				vhm.Pnics = append(vhm.Pnics, ps.PnicDevice)
+			}
+		}
+		res.HostMembers = append(res.HostMembers, vhm)
+	}
+	res.DefaultPolicy = convertDVPortSetting2External(cfg.DefaultPortConfig)
+	for _, f := range dvPortPolicyFlags(res.DefaultPolicy, false) {
+		res.Flags = append(res.Flags, "default port policy: "+f)
+	}
+	vmwCfg, ok := dvs.Config.(*types.VMwareDVSConfigInfo)
+	if !ok {
+		return res
+	}
+	res.MaxMtu = vmwCfg.MaxMtu
+	if ipfix := vmwCfg.IpfixConfig; ipfix != nil {
+		res.Ipfix = &vcDVSIpfix{
+			CollectorIpAddress:  ipfix.CollectorIpAddress,
+			CollectorPort:       ipfix.CollectorPort,
+			ObservationDomainId: ipfix.ObservationDomainId,
+			SamplingRate:        ipfix.SamplingRate,
+			InternalFlowsOnly:   ipfix.InternalFlowsOnly,
+		}
+		if ipfix.CollectorIpAddress != "" {
+			res.Flags = append(res.Flags, fmt.Sprintf("ipfix collector configured: %s:%d",
+				ipfix.CollectorIpAddress, ipfix.CollectorPort))
+		}
+	}
+	for _, vs := range vmwCfg.VspanSession {
+		ms := &vcDVSMirrorSession{
+			Key:                  vs.Key,
+			Name:                 vs.Name,
+			Description:          vs.Description,
+			Enabled:              vs.Enabled,
+			SessionType:          vs.SessionType,
+			SourceTransmitted:    describeVspanPort(vs.SourcePortTransmitted),
+			SourceReceived:       describeVspanPort(vs.SourcePortReceived),
+			Destination:          describeVspanPort(vs.DestinationPort),
+			EncapsulationVlanId:  vs.EncapsulationVlanId,
+			EncapType:            vs.EncapType,
+			ErspanId:             vs.ErspanId,
+			StripOriginalVlan:    vs.StripOriginalVlan,
+			NormalTrafficAllowed: vs.NormalTrafficAllowed,
+			MirroredPacketLength: vs.MirroredPacketLength,
+			SamplingRate:         vs.SamplingRate,
+		}
+		res.MirrorSessions = append(res.MirrorSessions, ms)
+		if !ms.Enabled {
+			continue
+		}
+		if vs.DestinationPort != nil && len(vs.DestinationPort.IpAddress) != 0 {
+			res.Flags = append(res.Flags, "mirror session "+ms.Name+" sends traffic to remote ip: "+
+				strings.Join(vs.DestinationPort.IpAddress, ","))
+		} else {
+			res.Flags = append(res.Flags, "mirror session "+ms.Name+" enabled, destination: "+ms.Destination)
+		}
+	}
+	return res
+}
+
+func convertDVPortSetting2External(bps types.BaseDVPortSetting) *vcDVPortPolicy {
+	ps, ok := bps.(*types.VMwareDVSPortSetting)
+	if !ok || ps == nil {
+		return nil
+	}
+	res := &vcDVPortPolicy{
+		IpfixEnabled: boolPolicyValue(ps.IpfixEnabled),
+		Blocked:      boolPolicyValue(ps.Blocked),
+	}
+	switch vlan := ps.Vlan.(type) {
+	case *types.VmwareDistributedVirtualSwitchVlanIdSpec:
+		res.VlanType = "vlan"
+		res.Vlan = fmt.Sprintf("%d", vlan.VlanId)
+	case *types.VmwareDistributedVirtualSwitchTrunkVlanSpec:
+		res.VlanType = "trunk"
+		ranges := make([]string, 0, len(vlan.VlanId))
+		for _, r := range vlan.VlanId {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", r.Start, r.End))
+		}
+		res.Vlan = strings.Join(ranges, ",")
+	case *types.VmwareDistributedVirtualSwitchPvlanSpec:
+		res.VlanType = "pvlan"
+		res.Vlan = fmt.Sprintf("%d", vlan.PvlanId)
+	}
+	if sp := ps.SecurityPolicy; sp != nil {
+		res.AllowPromiscuous = boolPolicyValue(sp.AllowPromiscuous)
+		res.MacChanges = boolPolicyValue(sp.MacChanges)
+		res.ForgedTransmits = boolPolicyValue(sp.ForgedTransmits)
+	}
+	if tp := ps.UplinkTeamingPolicy; tp != nil && tp.UplinkPortOrder != nil {
+		res.ActiveUplinks = tp.UplinkPortOrder.ActiveUplinkPort
+		res.StandbyUplinks = tp.UplinkPortOrder.StandbyUplinkPort
+	}
+	return res
+}
+
+// dvPortPolicyFlags flag security policy allowing sniffing / spoofing and trunk carrying all VLANs to VMs.
+func dvPortPolicyFlags(p *vcDVPortPolicy, isUplink bool) []string {
+	if p == nil {
+		return nil
+	}
+	res := make([]string, 0)
+	if isTrue(p.AllowPromiscuous) {
+		res = append(res, "promiscuous mode allowed")
+	}
+	if isTrue(p.MacChanges) {
+		res = append(res, "mac address changes allowed")
+	}
+	if isTrue(p.ForgedTransmits) {
+		res = append(res, "forged transmits allowed")
+	}
+	// uplink port group is always trunked
+	if !isUplink && p.VlanType == "trunk" && p.Vlan == "0-4094" {
+		res = append(res, "all vlans trunked to virtual machines")
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func describeVspanPort(vp *types.VMwareVspanPort) string {
+	if vp == nil {
+		return ""
+	}
+	parts := make([]string, 0)
+	if len(vp.PortKey) != 0 {
+		parts = append(parts, "ports="+strings.Join(vp.PortKey, ","))
+	}
+	if len(vp.UplinkPortName) != 0 {
+		parts = append(parts, "uplinks="+strings.Join(vp.UplinkPortName, ","))
+	}
+	if len(vp.WildcardPortConnecteeType) != 0 {
+		parts = append(parts, "wildcard="+strings.Join(vp.WildcardPortConnecteeType, ","))
+	}
+	if len(vp.Vlans) != 0 {
+		parts = append(parts, fmt.Sprintf("vlans=%v", vp.Vlans))
+	}
+	if len(vp.IpAddress) != 0 {
+		parts = append(parts, "ip="+strings.Join(vp.IpAddress, ","))
+	}
+	return strings.Join(parts, " ")
+}
+
+func boolPolicyValue(bp *types.BoolPolicy) *bool {
+	if bp == nil {
+		return nil
+	}
+	return bp.Value
+}
+
+func isTrue(b *bool) bool {
+	return b != nil && *b
+}
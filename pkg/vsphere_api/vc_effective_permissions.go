@@ -0,0 +1,640 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"html"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPermissionListEmpty = errors.New("permission list is empty, run ListPermissions first")
+	ErrCredentialNotFound  = errors.New("credentials of current session not found")
+	ErrMOBNonceNotFound    = errors.New("session nonce not found in managed object browser response")
+	ErrMOBResultNotFound   = errors.New("method invocation result not found in managed object browser response")
+)
+
+var (
+	mobSessionNonceRe = regexp.MustCompile(`name="vmware-session-nonce" type="hidden" value="?([^\s^"]+)"`)
+	htmlTagRe         = regexp.MustCompile(`<[^>]+>`)
+)
+
+const (
+	// globalPermissionEntity is used as entity of global permissions, they apply above root folder of every vCenter
+	globalPermissionEntity = "(global)"
+)
+
+type EffectivePermissionReport struct {
+	CollectedAt       time.Time       `json:"collected_at"`
+	GlobalPermissions []*vcPermission `json:"global_permissions,omitempty"`
+	// GlobalPermissionsStatus is one of: parsed, parsed_empty (page parsed but no entry found), failed
+	GlobalPermissionsStatus string `json:"global_permissions_status"`
+	// GlobalPermissionsError is set if global permissions cannot be retrieved, result is incomplete in this case
+	GlobalPermissionsError string `json:"global_permissions_error,omitempty"`
+	// UnresolvedGroups cannot be expanded via SSO, they are reported as principal themselves
+	UnresolvedGroups []string             `json:"unresolved_groups,omitempty"`
+	Principals       []*vcPrincipalAccess `json:"principals"`
+	Objects          []*vcObjectAccess    `json:"objects"`
+}
+
+type vcPrincipalAccess struct {
+	Principal string `json:"principal"`
+	// Kind is one of: user, solution_user, group (only for unresolved group)
+	Kind     string   `json:"kind"`
+	MemberOf []string `json:"member_of,omitempty"`
+	// Grants are permissions assigned to this principal directly or via groups, whether overridden or not
+	Grants []*vcEffectiveGrant `json:"grants"`
+	// ObjectCount is the number of objects this principal has any privilege on
+	ObjectCount int      `json:"object_count"`
+	Privileges  []string `json:"privileges,omitempty"`
+}
+
+type vcEffectiveGrant struct {
+	Entity    string `json:"entity"`
+	RoleId    int32  `json:"role_id"`
+	RoleName  string `json:"role_name"`
+	Propagate bool   `json:"propagate"`
+	// Via is "direct" or the group this permission is assigned to
+	Via string `json:"via"`
+}
+
+type vcObjectAccess struct {
+	Entity string                     `json:"entity"`
+	Type   string                     `json:"type"`
+	Access []*vcObjectPrincipalAccess `json:"access,omitempty"`
+}
+
+type vcObjectPrincipalAccess struct {
+	Principal      string   `json:"principal"`
+	Roles          []string `json:"roles"`
+	PrivilegeCount int      `json:"privilege_count"`
+	// DefinedOn is the entity where the winning permissions are defined
+	DefinedOn string   `json:"defined_on"`
+	Via       []string `json:"via"`
+	// privileges are only used to build per-principal summary, too verbose to output per object
+	privileges []string
+}
+
+// vcInvtNode is an inventory entity with all parents permissions propagate from
+type vcInvtNode struct {
+	ref     types.ManagedObjectReference
+	name    string
+	path    string
+	parents []types.ManagedObjectReference
+}
+
+// vcResolvedPrincipal is a principal permissions are evaluated for, with transitive group membership
+type vcResolvedPrincipal struct {
+	key      string
+	kind     string
+	memberOf map[string]bool
+}
+
+// ResolveEffectivePermissions expand SSO group members not yet expanded by ListSSOGroupMembers, evaluate permissions
+// from ListPermissions and global permissions down the inventory tree, output per-principal and per-object reports.
+// Evaluation follows vSphere rules: permission on the nearest entity wins, permission of user itself overrides those
+// of groups on the same entity, permissions of multiple groups on the same entity are unioned, virtual machines
+// inherit from both folder and resource pool.
+func (vsc *vSphereClient) ResolveEffectivePermissions(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if len(vcbi.VCAuthoriPerm) == 0 {
+		return ErrPermissionListEmpty
+	}
+	report := &EffectivePermissionReport{CollectedAt: time.Now()}
+	roleById := make(map[int32]*vcAuthorizationRole)
+	for _, r := range vcbi.VCAuthoriRole {
+		roleById[r.RoleId] = r
+	}
+	var err error
+	report.GlobalPermissions, err = vsc.retrieveGlobalPermissions()
+	switch {
+	case err != nil:
+		log.Errorln("retrieve global permissions, err: ", err)
+		report.GlobalPermissionsStatus = "failed"
+		report.GlobalPermissionsError = err.Error()
+	case len(report.GlobalPermissions) == 0:
+		log.Warnln("global permissions page parsed, but no entry found.")
+		report.GlobalPermissionsStatus = "parsed_empty"
+	default:
+		report.GlobalPermissionsStatus = "parsed"
+	}
+	// group principals to expand
+	perms := make([]*vcPermission, 0, len(vcbi.VCAuthoriPerm)+len(report.GlobalPermissions))
+	for _, p := range vcbi.VCAuthoriPerm {
+		// conversion failure leaves nil in permission list
+		if p != nil {
+			perms = append(perms, p)
+		}
+	}
+	perms = append(perms, report.GlobalPermissions...)
+	groupNames := make([]string, 0)
+	for _, p := range perms {
+		if p.IsGroup {
+			groupNames = append(groupNames, normalizePrincipal(p.Principal))
+		}
+	}
+	// reuse groups expanded by ListSSOGroupMembers, only those missing there are expanded, e.g. groups from
+	// external identity source which only appear in permissions
+	membership := make(map[string]*ssoGroupMembers, len(vcbi.ssoMembership))
+	for k, v := range vcbi.ssoMembership {
+		membership[k] = v
+	}
+	missingGroups := make([]string, 0)
+	for _, g := range groupNames {
+		if _, ok := membership[g]; !ok {
+			missingGroups = append(missingGroups, g)
+		}
+	}
+	if len(missingGroups) != 0 {
+		ssocli, err := vsc.Login2SSOMgmt()
+		if err != nil || ssocli == nil {
+			log.Errorln("cannot create ssoadmin client, missing group members will not be expanded, err:", err)
+		} else {
+			expandSSOGroups(ssocli, dedupStrings(missingGroups), membership)
+		}
+	}
+	principals := resolvePrincipals(perms, membership, report)
+	nodes, err := vsc.retrieveInventoryTree()
+	if err != nil {
+		return err
+	}
+	permsByEntity := make(map[types.ManagedObjectReference][]*vcPermission)
+	for _, p := range vcbi.VCAuthoriPerm {
+		if p != nil {
+			permsByEntity[p.entityRef] = append(permsByEntity[p.entityRef], p)
+		}
+	}
+	// per object evaluation
+	privsByPrincipal := make(map[string]map[string]bool)
+	objCntByPrincipal := make(map[string]int)
+	report.Objects = make([]*vcObjectAccess, 0, len(nodes))
+	for _, n := range nodes {
+		levels := ancestorLevels(n.ref, nodes)
+		oa := &vcObjectAccess{Entity: n.path, Type: n.ref.Type}
+		for _, pr := range principals {
+			opa := evaluatePrincipalAccess(pr, levels, permsByEntity, report.GlobalPermissions, nodes, roleById)
+			if opa == nil {
+				continue
+			}
+			oa.Access = append(oa.Access, opa)
+			if opa.PrivilegeCount == 0 {
+				continue
+			}
+			objCntByPrincipal[pr.key]++
+			if privsByPrincipal[pr.key] == nil {
+				privsByPrincipal[pr.key] = make(map[string]bool)
+			}
+			for _, priv := range opa.privileges {
+				privsByPrincipal[pr.key][priv] = true
+			}
+		}
+		report.Objects = append(report.Objects, oa)
+	}
+	sort.SliceStable(report.Objects, func(i, j int) bool {
+		return report.Objects[i].Entity < report.Objects[j].Entity
+	})
+	// per principal summary
+	for _, pr := range principals {
+		pa := &vcPrincipalAccess{
+			Principal:   pr.key,
+			Kind:        pr.kind,
+			ObjectCount: objCntByPrincipal[pr.key],
+		}
+		for g := range pr.memberOf {
+			pa.MemberOf = append(pa.MemberOf, g)
+		}
+		sort.Strings(pa.MemberOf)
+		for _, p := range perms {
+			via, ok := principalMatches(pr, p)
+			if !ok {
+				continue
+			}
+			pa.Grants = append(pa.Grants, &vcEffectiveGrant{
+				Entity:    p.Entity,
+				RoleId:    p.RoleId,
+				RoleName:  roleName(roleById, p.RoleId),
+				Propagate: p.Propagate,
+				Via:       via,
+			})
+		}
+		for priv := range privsByPrincipal[pr.key] {
+			pa.Privileges = append(pa.Privileges, priv)
+		}
+		sort.Strings(pa.Privileges)
+		report.Principals = append(report.Principals, pa)
+	}
+	log.Infof("effective permissions resolved for %d principals on %d objects.", len(report.Principals),
+		len(report.Objects))
+	fPath, err := SaveJSONOutput("EffectivePermissions", report)
+	if err != nil {
+		log.Errorln("save effective permissions json, err: ", err)
+		return err
+	}
+	log.Infoln("effective permissions stored in json: ", fPath)
+	return saveEffectivePermissionsCSV(report.Objects)
+}
+
+// resolvePrincipals build principal list: users assigned directly, users in groups (transitively), and groups
+// which cannot be expanded.
+func resolvePrincipals(perms []*vcPermission, membership map[string]*ssoGroupMembers,
+	report *EffectivePermissionReport) []*vcResolvedPrincipal {
+	res := make(map[string]*vcResolvedPrincipal)
+	addUser := func(key string, kind string) *vcResolvedPrincipal {
+		if res[key] == nil {
+			res[key] = &vcResolvedPrincipal{key: key, kind: kind, memberOf: make(map[string]bool)}
+		}
+		return res[key]
+	}
+	var walk func(root string, g string, visited map[string]bool)
+	walk = func(root string, g string, visited map[string]bool) {
+		if visited[g] {
+			return
+		}
+		visited[g] = true
+		gm := membership[g]
+		if gm == nil {
+			return
+		}
+		for _, u := range gm.Users {
+			addUser(u, "user").memberOf[root] = true
+		}
+		for _, u := range gm.SolutionUsers {
+			addUser(u, "solution_user").memberOf[root] = true
+		}
+		for _, sg := range gm.Groups {
+			walk(root, sg, visited)
+		}
+	}
+	for _, p := range perms {
+		key := normalizePrincipal(p.Principal)
+		if !p.IsGroup {
+			addUser(key, "user")
+			continue
+		}
+		if gm, ok := membership[key]; !ok || gm.err != nil {
+			if res[key] == nil {
+				res[key] = &vcResolvedPrincipal{key: key, kind: "group", memberOf: make(map[string]bool)}
+				report.UnresolvedGroups = append(report.UnresolvedGroups, key)
+			}
+			continue
+		}
+		walk(key, key, make(map[string]bool))
+	}
+	sorted := make([]*vcResolvedPrincipal, 0, len(res))
+	for _, v := range res {
+		sorted = append(sorted, v)
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].key < sorted[j].key
+	})
+	sort.Strings(report.UnresolvedGroups)
+	return sorted
+}
+
+// principalMatches return whether permission applies to principal, and "direct" or group name it applies via.
+func principalMatches(pr *vcResolvedPrincipal, p *vcPermission) (string, bool) {
+	key := normalizePrincipal(p.Principal)
+	if key == pr.key {
+		return "direct", true
+	}
+	if p.IsGroup && pr.memberOf[key] {
+		return key, true
+	}
+	return "", false
+}
+
+// evaluatePrincipalAccess find the nearest level with permissions applying to principal, user permissions
+// override group permissions on the same level. Global permissions are the last level. Return nil if no
+// permission applies.
+func evaluatePrincipalAccess(pr *vcResolvedPrincipal, levels [][]types.ManagedObjectReference,
+	permsByEntity map[types.ManagedObjectReference][]*vcPermission, globalPerms []*vcPermission,
+	nodes map[types.ManagedObjectReference]*vcInvtNode, roleById map[int32]*vcAuthorizationRole) *vcObjectPrincipalAccess {
+	evalLevel := func(candidates []*vcPermission, mustPropagate bool) (direct []*vcPermission,
+		viaGroup []*vcPermission, vias []string) {
+		for _, p := range candidates {
+			if mustPropagate && !p.Propagate {
+				continue
+			}
+			via, ok := principalMatches(pr, p)
+			if !ok {
+				continue
+			}
+			if via == "direct" {
+				direct = append(direct, p)
+			} else {
+				viaGroup = append(viaGroup, p)
+				vias = append(vias, via)
+			}
+		}
+		return
+	}
+	// definedOn is the first entity holding winning permissions, i.e. user permissions if any, else group ones
+	buildAccess := func(direct []*vcPermission, viaGroup []*vcPermission, vias []string,
+		directOn string, groupOn string) *vcObjectPrincipalAccess {
+		winning, definedOn := viaGroup, groupOn
+		if len(direct) != 0 {
+			winning, vias, definedOn = direct, []string{"direct"}, directOn
+		}
+		if len(winning) == 0 {
+			return nil
+		}
+		opa := &vcObjectPrincipalAccess{Principal: pr.key, DefinedOn: definedOn, Via: dedupStrings(vias)}
+		privs := make(map[string]bool)
+		for _, p := range winning {
+			opa.Roles = append(opa.Roles, roleName(roleById, p.RoleId))
+			if r := roleById[p.RoleId]; r != nil {
+				for _, priv := range r.Privileges {
+					privs[priv] = true
+				}
+			}
+		}
+		opa.Roles = dedupStrings(opa.Roles)
+		for priv := range privs {
+			opa.privileges = append(opa.privileges, priv)
+		}
+		opa.PrivilegeCount = len(privs)
+		return opa
+	}
+	for depth, lvl := range levels {
+		var direct, viaGroup []*vcPermission
+		var vias []string
+		directOn, groupOn := "", ""
+		for _, ref := range lvl {
+			d, g, v := evalLevel(permsByEntity[ref], depth != 0)
+			if len(d) != 0 && directOn == "" {
+				directOn = nodes[ref].path
+			}
+			if len(g) != 0 && groupOn == "" {
+				groupOn = nodes[ref].path
+			}
+			direct, viaGroup, vias = append(direct, d...), append(viaGroup, g...), append(vias, v...)
+		}
+		if opa := buildAccess(direct, viaGroup, vias, directOn, groupOn); opa != nil {
+			return opa
+		}
+	}
+	direct, viaGroup, vias := evalLevel(globalPerms, true)
+	return buildAccess(direct, viaGroup, vias, globalPermissionEntity, globalPermissionEntity)
+}
+
+// ancestorLevels return entity itself, then parents level by level up to root folder.
+func ancestorLevels(ref types.ManagedObjectReference,
+	nodes map[types.ManagedObjectReference]*vcInvtNode) [][]types.ManagedObjectReference {
+	res := make([][]types.ManagedObjectReference, 0)
+	visited := map[types.ManagedObjectReference]bool{ref: true}
+	cur := []types.ManagedObjectReference{ref}
+	for len(cur) != 0 {
+		res = append(res, cur)
+		next := make([]types.ManagedObjectReference, 0)
+		for _, c := range cur {
+			n := nodes[c]
+			if n == nil {
+				continue
+			}
+			for _, p := range n.parents {
+				if !visited[p] {
+					visited[p] = true
+					next = append(next, p)
+				}
+			}
+		}
+		cur = next
+	}
+	return res
+}
+
+// retrieveInventoryTree retrieve every managed entity with its parents, inventory path is built from names.
+func (vsc *vSphereClient) retrieveInventoryTree() (map[types.ManagedObjectReference]*vcInvtNode, error) {
+	tmpCtx := context.Background()
+	rootRef := vsc.vmwSoapClient.ServiceContent.RootFolder
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(tmpCtx, rootRef, []string{"ManagedEntity"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpCtx)
+	}()
+	var entities []mo.ManagedEntity
+	err = ctnrView.Retrieve(tmpCtx, []string{"ManagedEntity"}, []string{"name", "parent"}, &entities)
+	if err != nil {
+		return nil, err
+	}
+	var vms []mo.VirtualMachine
+	err = ctnrView.Retrieve(tmpCtx, []string{"VirtualMachine"}, []string{"resourcePool"}, &vms)
+	if err != nil {
+		return nil, err
+	}
+	nodes := map[types.ManagedObjectReference]*vcInvtNode{rootRef: {ref: rootRef, path: "/"}}
+	for _, e := range entities {
+		n := &vcInvtNode{ref: e.Self, name: e.Name}
+		if e.Parent != nil {
+			n.parents = append(n.parents, *e.Parent)
+		}
+		nodes[e.Self] = n
+	}
+	// virtual machine inherits from resource pool or vApp as well
+	for _, vm := range vms {
+		if n := nodes[vm.Self]; n != nil && vm.ResourcePool != nil {
+			n.parents = append(n.parents, *vm.ResourcePool)
+		}
+	}
+	var buildPath func(n *vcInvtNode, depth int) string
+	buildPath = func(n *vcInvtNode, depth int) string {
+		if n.path != "" {
+			return n.path
+		}
+		if len(n.parents) == 0 || nodes[n.parents[0]] == nil || depth > 64 {
+			n.path = "/" + n.name
+			return n.path
+		}
+		n.path = strings.TrimSuffix(buildPath(nodes[n.parents[0]], depth+1), "/") + "/" + n.name
+		return n.path
+	}
+	for _, n := range nodes {
+		buildPath(n, 0)
+	}
+	return nodes, nil
+}
+
+// retrieveGlobalPermissions read global permissions via managed object browser of inventory service, which is the
+// only interface exposing them. Basic authentication with credentials of current session is required.
+func (vsc *vSphereClient) retrieveGlobalPermissions() ([]*vcPermission, error) {
+	if vsc.soapURL == nil || vsc.soapURL.User == nil {
+		return nil, ErrCredentialNotFound
+	}
+	userName := vsc.soapURL.User.Username()
+	password, _ := vsc.soapURL.User.Password()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	httpCli := &http.Client{Transport: http.DefaultTransport, Jar: jar}
+	mobURL := url.URL{
+		Scheme:   vsc.soapURL.Scheme,
+		Host:     vsc.soapURL.Host,
+		Path:     "/invsvc/mob3/",
+		RawQuery: "moid=authorizationService&method=AuthorizationService.GetGlobalAccessControlList",
+	}
+	doReq := func(method string, u string, body io.Reader) (string, error) {
+		req, err := http.NewRequest(method, u, body)
+		if err != nil {
+			return "", err
+		}
+		req.SetBasicAuth(userName, password)
+		if body != nil {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		resp, err := httpCli.Do(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return "", err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return "", errors.New("unexpected response status: " + resp.Status)
+		}
+		return string(data), nil
+	}
+	page, err := doReq(http.MethodGet, mobURL.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		logoutURL := url.URL{Scheme: mobURL.Scheme, Host: mobURL.Host, Path: "/invsvc/mob3/logout"}
+		_, _ = doReq(http.MethodGet, logoutURL.String(), nil)
+	}()
+	m := mobSessionNonceRe.FindStringSubmatch(page)
+	if len(m) != 2 {
+		return nil, ErrMOBNonceNotFound
+	}
+	page, err = doReq(http.MethodPost, mobURL.String(),
+		strings.NewReader("vmware-session-nonce="+url.QueryEscape(m[1])))
+	if err != nil {
+		return nil, err
+	}
+	return parseGlobalAccessControlList(page)
+}
+
+// parseGlobalAccessControlList parse result page of managed object browser, each property is rendered as a row
+// of name, type and value cells. Page without invocation result is an error, so that markup change is not
+// reported as empty global permissions.
+func parseGlobalAccessControlList(page string) ([]*vcPermission, error) {
+	if !strings.Contains(page, "Method Invocation Result") {
+		return nil, ErrMOBResultNotFound
+	}
+	tokens := make([]string, 0)
+	for _, t := range strings.Split(htmlTagRe.ReplaceAllString(page, "\n"), "\n") {
+		t = strings.Trim(strings.TrimSpace(html.UnescapeString(t)), "\"")
+		if t != "" {
+			tokens = append(tokens, t)
+		}
+	}
+	res := make([]*vcPermission, 0)
+	cur := &vcPermission{Entity: globalPermissionEntity}
+	var roles []int32
+	for i := 0; i+2 < len(tokens); i++ {
+		switch {
+		case tokens[i] == "name" && tokens[i+1] == "string":
+			cur.Principal = tokens[i+2]
+		case tokens[i] == "group" && tokens[i+1] == "boolean":
+			cur.IsGroup = tokens[i+2] == "true"
+		case tokens[i] == "roles" && tokens[i+1] == "long[]":
+			roles = roles[:0]
+			for j := i + 2; j < len(tokens); j++ {
+				rid, err := strconv.ParseInt(tokens[j], 10, 32)
+				if err != nil {
+					break
+				}
+				roles = append(roles, int32(rid))
+			}
+		case tokens[i] == "propagate" && tokens[i+1] == "boolean":
+			cur.Propagate = tokens[i+2] == "true"
+			// propagate is the last property of an access control entry
+			for _, rid := range roles {
+				p := *cur
+				p.RoleId = rid
+				res = append(res, &p)
+			}
+			cur = &vcPermission{Entity: globalPermissionEntity}
+			roles = roles[:0]
+		}
+	}
+	return res, nil
+}
+
+func saveEffectivePermissionsCSV(objects []*vcObjectAccess) error {
+	wDstFilePath := filepath.Join("output", "EffectivePermissions_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Entity", "Type", "Principal", "Roles", "Privilege Count", "Defined On", "Via"})
+	if err != nil {
+		return err
+	}
+	for _, o := range objects {
+		for _, a := range o.Access {
+			err = cwr.Write([]string{o.Entity, o.Type, a.Principal, strings.Join(a.Roles, "AND"),
+				strconv.Itoa(a.PrivilegeCount), a.DefinedOn, strings.Join(a.Via, "AND")})
+			if err != nil {
+				log.Errorln("csv write error:", err)
+				continue
+			}
+		}
+	}
+	log.Infoln("effective permissions stored in csv: ", wDstFilePath)
+	return nil
+}
+
+// normalizePrincipal convert "DOMAIN\name" used by permissions and "name@domain" used by SSO to lower-case
+// "name@domain".
+func normalizePrincipal(p string) string {
+	if i := strings.Index(p, "\\"); i >= 0 {
+		return strings.ToLower(p[i+1:] + "@" + p[:i])
+	}
+	return strings.ToLower(p)
+}
+
+func roleName(roleById map[int32]*vcAuthorizationRole, id int32) string {
+	if r := roleById[id]; r != nil {
+		return r.Name
+	}
+	return strconv.Itoa(int(id))
+}
+
+func dedupStrings(in []string) []string {
+	seen := make(map[string]bool)
+	res := make([]string, 0, len(in))
+	for _, s := range in {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	return res
+}
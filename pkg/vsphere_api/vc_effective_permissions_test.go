@@ -0,0 +1,318 @@
+package vsphere_api
+
+import (
+	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseGlobalAccessControlList(t *testing.T) {
+	page, err := os.ReadFile(filepath.Join("testdata", "invsvc_mob3_global_acl.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	perms, err := parseGlobalAccessControlList(string(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []vcPermission{
+		{Principal: "VSPHERE.LOCAL\\Administrator", IsGroup: false, RoleId: -1, Propagate: true},
+		{Principal: "VSPHERE.LOCAL\\Administrators", IsGroup: true, RoleId: -1, Propagate: true},
+		{Principal: "CORP\\vSphere-Ops & Backup", IsGroup: true, RoleId: -2, Propagate: false},
+		{Principal: "CORP\\vSphere-Ops & Backup", IsGroup: true, RoleId: 1234, Propagate: false},
+		{Principal: "CORP\\svc-backup", IsGroup: false, RoleId: -5, Propagate: false},
+	}
+	if len(perms) != len(expected) {
+		t.Fatalf("got %d permissions, expected %d", len(perms), len(expected))
+	}
+	for i, p := range perms {
+		e := expected[i]
+		if p.Entity != globalPermissionEntity || p.Principal != e.Principal || p.IsGroup != e.IsGroup ||
+			p.RoleId != e.RoleId || p.Propagate != e.Propagate {
+			t.Errorf("permission %d: got %+v, expected %+v", i, *p, e)
+		}
+	}
+	roleById := map[int32]*vcAuthorizationRole{-1: {RoleId: -1, Name: "Admin"}}
+	if n := roleName(roleById, -1); n != "Admin" {
+		t.Errorf("role name of -1: got %s, expected Admin", n)
+	}
+	// unknown role id is reported as is
+	if n := roleName(roleById, 1234); n != "1234" {
+		t.Errorf("role name of 1234: got %s, expected 1234", n)
+	}
+}
+
+func TestParseGlobalAccessControlListEmpty(t *testing.T) {
+	page := `<html><body><table><tr><td class="header-banner-title">Method Invocation Result: AccessControl[]</td>
+</tr></table><table class="clean"><tr><th>NAME</th><th>TYPE</th><th>VALUE</th></tr></table></body></html>`
+	perms, err := parseGlobalAccessControlList(page)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(perms) != 0 {
+		t.Errorf("got %d permissions, expected none", len(perms))
+	}
+}
+
+func TestParseGlobalAccessControlListNoResult(t *testing.T) {
+	page := `<html><body><form method="post"><input name="vmware-session-nonce" type="hidden" value="abc">
+<input type="submit" value="Invoke Method"></form></body></html>`
+	_, err := parseGlobalAccessControlList(page)
+	if err != ErrMOBResultNotFound {
+		t.Errorf("got err %v, expected %v", err, ErrMOBResultNotFound)
+	}
+}
+
+var (
+	testRootRef    = types.ManagedObjectReference{Type: "Folder", Value: "group-d1"}
+	testDCRef      = types.ManagedObjectReference{Type: "Datacenter", Value: "datacenter-2"}
+	testVMFldRef   = types.ManagedObjectReference{Type: "Folder", Value: "group-v3"}
+	testProdFldRef = types.ManagedObjectReference{Type: "Folder", Value: "group-v10"}
+	testHostFldRef = types.ManagedObjectReference{Type: "Folder", Value: "group-h4"}
+	testClusterRef = types.ManagedObjectReference{Type: "ClusterComputeResource", Value: "domain-c7"}
+	testRPRef      = types.ManagedObjectReference{Type: "ResourcePool", Value: "resgroup-8"}
+	testVMRef      = types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-20"}
+)
+
+// testInvtNodes build inventory tree: vm-20 is in folder Prod and resource pool of cluster C0 at the same time.
+func testInvtNodes() map[types.ManagedObjectReference]*vcInvtNode {
+	nodes := make(map[types.ManagedObjectReference]*vcInvtNode)
+	add := func(ref types.ManagedObjectReference, path string, parents ...types.ManagedObjectReference) {
+		nodes[ref] = &vcInvtNode{ref: ref, name: filepath.Base(path), path: path, parents: parents}
+	}
+	add(testRootRef, "/")
+	add(testDCRef, "/DC0", testRootRef)
+	add(testVMFldRef, "/DC0/vm", testDCRef)
+	add(testProdFldRef, "/DC0/vm/Prod", testVMFldRef)
+	add(testHostFldRef, "/DC0/host", testDCRef)
+	add(testClusterRef, "/DC0/host/C0", testHostFldRef)
+	add(testRPRef, "/DC0/host/C0/Resources", testClusterRef)
+	add(testVMRef, "/DC0/vm/Prod/vm01", testProdFldRef, testRPRef)
+	return nodes
+}
+
+func testRoles() map[int32]*vcAuthorizationRole {
+	return map[int32]*vcAuthorizationRole{
+		-1: {RoleId: -1, Name: "Admin", Privileges: []string{"System.Read", "VirtualMachine.Interact.PowerOn",
+			"VirtualMachine.State.CreateSnapshot", "Global.Settings"}},
+		1: {RoleId: 1, Name: "Read", Privileges: []string{"System.Read"}},
+		2: {RoleId: 2, Name: "Power", Privileges: []string{"System.Read", "VirtualMachine.Interact.PowerOn"}},
+		3: {RoleId: 3, Name: "Snap", Privileges: []string{"System.Read", "VirtualMachine.State.CreateSnapshot"}},
+	}
+}
+
+func TestAncestorLevels(t *testing.T) {
+	cycA := types.ManagedObjectReference{Type: "Folder", Value: "group-a"}
+	cycB := types.ManagedObjectReference{Type: "Folder", Value: "group-b"}
+	cyclic := map[types.ManagedObjectReference]*vcInvtNode{
+		cycA: {ref: cycA, parents: []types.ManagedObjectReference{cycB}},
+		cycB: {ref: cycB, parents: []types.ManagedObjectReference{cycA}},
+	}
+	unknown := types.ManagedObjectReference{Type: "VirtualMachine", Value: "vm-404"}
+	cases := []struct {
+		name     string
+		ref      types.ManagedObjectReference
+		nodes    map[types.ManagedObjectReference]*vcInvtNode
+		expected [][]types.ManagedObjectReference
+	}{
+		{name: "root folder", ref: testRootRef, nodes: testInvtNodes(),
+			expected: [][]types.ManagedObjectReference{{testRootRef}}},
+		{name: "folder chain", ref: testProdFldRef, nodes: testInvtNodes(),
+			expected: [][]types.ManagedObjectReference{{testProdFldRef}, {testVMFldRef}, {testDCRef}, {testRootRef}}},
+		// both parents are on the first level, common ancestor only appears once on the nearest level
+		{name: "vm dual parents", ref: testVMRef, nodes: testInvtNodes(),
+			expected: [][]types.ManagedObjectReference{{testVMRef}, {testProdFldRef, testRPRef},
+				{testVMFldRef, testClusterRef}, {testDCRef, testHostFldRef}, {testRootRef}}},
+		{name: "cyclic parents", ref: cycA, nodes: cyclic,
+			expected: [][]types.ManagedObjectReference{{cycA}, {cycB}}},
+		{name: "unknown entity", ref: unknown, nodes: testInvtNodes(),
+			expected: [][]types.ManagedObjectReference{{unknown}}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := ancestorLevels(c.ref, c.nodes)
+			if !reflect.DeepEqual(got, c.expected) {
+				t.Errorf("got %v, expected %v", got, c.expected)
+			}
+		})
+	}
+}
+
+func TestEvaluatePrincipalAccess(t *testing.T) {
+	alice := &vcResolvedPrincipal{key: "alice@corp", kind: "user",
+		memberOf: map[string]bool{"ops@corp": true, "backup@corp": true}}
+	userPerm := func(ref types.ManagedObjectReference, roleId int32, propagate bool) *vcPermission {
+		return &vcPermission{Principal: "CORP\\Alice", RoleId: roleId, Propagate: propagate, entityRef: ref}
+	}
+	groupPerm := func(ref types.ManagedObjectReference, group string, roleId int32) *vcPermission {
+		return &vcPermission{Principal: "CORP\\" + group, IsGroup: true, RoleId: roleId, Propagate: true,
+			entityRef: ref}
+	}
+	cases := []struct {
+		name        string
+		ref         types.ManagedObjectReference
+		perms       []*vcPermission
+		globalPerms []*vcPermission
+		// expectNil means no permission applies at all
+		expectNil      bool
+		expectRoles    []string
+		expectOn       string
+		expectVia      []string
+		expectPrivsCnt int
+	}{
+		{name: "nearest entity wins", ref: testVMRef,
+			perms:       []*vcPermission{userPerm(testRootRef, -1, true), userPerm(testProdFldRef, 1, true)},
+			expectRoles: []string{"Read"}, expectOn: "/DC0/vm/Prod", expectVia: []string{"direct"}, expectPrivsCnt: 1},
+		{name: "user overrides group on the same entity", ref: testVMRef,
+			perms:       []*vcPermission{groupPerm(testProdFldRef, "Ops", -1), userPerm(testProdFldRef, 2, true)},
+			expectRoles: []string{"Power"}, expectOn: "/DC0/vm/Prod", expectVia: []string{"direct"}, expectPrivsCnt: 2},
+		{name: "group union", ref: testVMRef,
+			perms:       []*vcPermission{groupPerm(testProdFldRef, "Ops", 1), groupPerm(testProdFldRef, "Backup", 3)},
+			expectRoles: []string{"Read", "Snap"}, expectOn: "/DC0/vm/Prod",
+			expectVia: []string{"ops@corp", "backup@corp"}, expectPrivsCnt: 2},
+		{name: "group of other principal does not apply", ref: testVMRef,
+			perms:     []*vcPermission{groupPerm(testProdFldRef, "Finance", -1)},
+			expectNil: true},
+		{name: "non-propagating permission on entity itself", ref: testProdFldRef,
+			perms:       []*vcPermission{userPerm(testRootRef, 1, true), userPerm(testProdFldRef, -1, false)},
+			expectRoles: []string{"Admin"}, expectOn: "/DC0/vm/Prod", expectVia: []string{"direct"}, expectPrivsCnt: 4},
+		{name: "non-propagating permission skipped on child", ref: testVMRef,
+			perms:       []*vcPermission{userPerm(testRootRef, 1, true), userPerm(testProdFldRef, -1, false)},
+			expectRoles: []string{"Read"}, expectOn: "/", expectVia: []string{"direct"}, expectPrivsCnt: 1},
+		{name: "vm inherits from resource pool", ref: testVMRef,
+			perms:       []*vcPermission{userPerm(testRootRef, 1, true), userPerm(testRPRef, 2, true)},
+			expectRoles: []string{"Power"}, expectOn: "/DC0/host/C0/Resources", expectVia: []string{"direct"},
+			expectPrivsCnt: 2},
+		{name: "vm dual parents on the same level are merged", ref: testVMRef,
+			perms:       []*vcPermission{groupPerm(testProdFldRef, "Ops", 1), groupPerm(testRPRef, "Backup", 3)},
+			expectRoles: []string{"Read", "Snap"}, expectOn: "/DC0/vm/Prod",
+			expectVia: []string{"ops@corp", "backup@corp"}, expectPrivsCnt: 2},
+		{name: "vm dual parents user overrides group", ref: testVMRef,
+			perms:       []*vcPermission{groupPerm(testProdFldRef, "Ops", -1), userPerm(testRPRef, 1, true)},
+			expectRoles: []string{"Read"}, expectOn: "/DC0/host/C0/Resources", expectVia: []string{"direct"},
+			expectPrivsCnt: 1},
+		{name: "global permission as the last level", ref: testVMRef,
+			globalPerms: []*vcPermission{groupPerm(types.ManagedObjectReference{}, "Ops", -1)},
+			expectRoles: []string{"Admin"}, expectOn: globalPermissionEntity, expectVia: []string{"ops@corp"},
+			expectPrivsCnt: 4},
+		{name: "inventory permission overrides global permission", ref: testVMRef,
+			perms:       []*vcPermission{userPerm(testRootRef, 1, true)},
+			globalPerms: []*vcPermission{userPerm(types.ManagedObjectReference{}, -1, true)},
+			expectRoles: []string{"Read"}, expectOn: "/", expectVia: []string{"direct"}, expectPrivsCnt: 1},
+		{name: "non-propagating global permission", ref: testVMRef,
+			globalPerms: []*vcPermission{userPerm(types.ManagedObjectReference{}, -1, false)},
+			expectNil:   true},
+	}
+	nodes := testInvtNodes()
+	roles := testRoles()
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			permsByEntity := make(map[types.ManagedObjectReference][]*vcPermission)
+			for _, p := range c.perms {
+				permsByEntity[p.entityRef] = append(permsByEntity[p.entityRef], p)
+			}
+			got := evaluatePrincipalAccess(alice, ancestorLevels(c.ref, nodes), permsByEntity, c.globalPerms, nodes,
+				roles)
+			if c.expectNil {
+				if got != nil {
+					t.Errorf("got %+v, expected nil", *got)
+				}
+				return
+			}
+			if got == nil {
+				t.Fatal("got nil, expected access")
+			}
+			if !reflect.DeepEqual(got.Roles, c.expectRoles) || got.DefinedOn != c.expectOn ||
+				!reflect.DeepEqual(got.Via, c.expectVia) || got.PrivilegeCount != c.expectPrivsCnt {
+				t.Errorf("got roles %v on %s via %v with %d privileges, expected roles %v on %s via %v with %d "+
+					"privileges", got.Roles, got.DefinedOn, got.Via, got.PrivilegeCount, c.expectRoles, c.expectOn,
+					c.expectVia, c.expectPrivsCnt)
+			}
+		})
+	}
+}
+
+func TestResolvePrincipals(t *testing.T) {
+	membership := map[string]*ssoGroupMembers{
+		"ops@corp": {Users: []string{"alice@corp"}, Groups: []string{"nested@corp"}},
+		// nested back to ops, walking must stop
+		"nested@corp": {Users: []string{"bob@corp"}, SolutionUsers: []string{"vpxd-abc@vsphere.local"},
+			Groups: []string{"ops@corp"}},
+		"backup@corp": {Users: []string{"alice@corp"}},
+		"ext@corp":    {err: ErrMOBResultNotFound},
+	}
+	type expectedPrincipal struct {
+		kind     string
+		memberOf []string
+	}
+	cases := []struct {
+		name             string
+		perms            []*vcPermission
+		expected         map[string]expectedPrincipal
+		expectUnresolved []string
+	}{
+		{name: "direct user",
+			perms:    []*vcPermission{{Principal: "CORP\\Alice"}},
+			expected: map[string]expectedPrincipal{"alice@corp": {kind: "user"}}},
+		{name: "nested and cyclic groups",
+			perms: []*vcPermission{{Principal: "CORP\\Ops", IsGroup: true}},
+			expected: map[string]expectedPrincipal{
+				"alice@corp":             {kind: "user", memberOf: []string{"ops@corp"}},
+				"bob@corp":               {kind: "user", memberOf: []string{"ops@corp"}},
+				"vpxd-abc@vsphere.local": {kind: "solution_user", memberOf: []string{"ops@corp"}},
+			}},
+		{name: "user in multiple groups and assigned directly",
+			perms: []*vcPermission{{Principal: "CORP\\Backup", IsGroup: true}, {Principal: "alice@corp"},
+				{Principal: "CORP\\Nested", IsGroup: true}, {Principal: "CORP\\Ops", IsGroup: true}},
+			expected: map[string]expectedPrincipal{
+				"alice@corp":             {kind: "user", memberOf: []string{"backup@corp", "nested@corp", "ops@corp"}},
+				"bob@corp":               {kind: "user", memberOf: []string{"nested@corp", "ops@corp"}},
+				"vpxd-abc@vsphere.local": {kind: "solution_user", memberOf: []string{"nested@corp", "ops@corp"}},
+			}},
+		{name: "unresolved groups",
+			perms: []*vcPermission{{Principal: "CORP\\Missing", IsGroup: true}, {Principal: "CORP\\Ext", IsGroup: true},
+				{Principal: "CORP\\Ext", IsGroup: true}},
+			expected: map[string]expectedPrincipal{
+				"ext@corp":     {kind: "group"},
+				"missing@corp": {kind: "group"},
+			},
+			expectUnresolved: []string{"ext@corp", "missing@corp"}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			report := &EffectivePermissionReport{}
+			got := resolvePrincipals(c.perms, membership, report)
+			if len(got) != len(c.expected) {
+				t.Fatalf("got %d principals, expected %d", len(got), len(c.expected))
+			}
+			for i, pr := range got {
+				if i > 0 && got[i-1].key >= pr.key {
+					t.Errorf("principals not sorted: %s after %s", pr.key, got[i-1].key)
+				}
+				e, ok := c.expected[pr.key]
+				if !ok {
+					t.Errorf("unexpected principal %s", pr.key)
+					continue
+				}
+				memberOf := make([]string, 0)
+				for g := range pr.memberOf {
+					memberOf = append(memberOf, g)
+				}
+				sort.Strings(memberOf)
+				if e.memberOf == nil {
+					e.memberOf = []string{}
+				}
+				if pr.kind != e.kind || !reflect.DeepEqual(memberOf, e.memberOf) {
+					t.Errorf("principal %s: got kind %s member of %v, expected kind %s member of %v", pr.key,
+						pr.kind, memberOf, e.kind, e.memberOf)
+				}
+			}
+			if !reflect.DeepEqual(report.UnresolvedGroups, c.expectUnresolved) {
+				t.Errorf("got unresolved groups %v, expected %v", report.UnresolvedGroups, c.expectUnresolved)
+			}
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package vsphere_api
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/vim25/types"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	ErrExtensionMgrNotAvailable = errors.New("extension manager is not available")
+)
+
+var (
+	//go:embed vc_known_extensions.json
+	vcKnownExtensionsRaw []byte
+)
+
+type vcKnownExtensions struct {
+	KeyPrefixes []string `json:"key_prefixes"`
+	Companies   []string `json:"companies"`
+}
+
+type vcExtension struct {
+	Key     string `json:"key"`
+	Label   string `json:"label,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	Company string `json:"company,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Version string `json:"version"`
+	// SolutionUser is the certificate subject extension logs in with
+	SolutionUser       string               `json:"solution_user,omitempty"`
+	Servers            []*vcExtensionServer `json:"servers,omitempty"`
+	ClientPlugins      []*vcExtensionClient `json:"client_plugins,omitempty"`
+	PrivilegeIds       []string             `json:"privilege_ids,omitempty"`
+	LastHeartbeatTime  time.Time            `json:"last_heartbeat_time"`
+	HealthURL          string               `json:"health_url,omitempty"`
+	ShownInSolutionMgr bool                 `json:"shown_in_solution_manager"`
+	Known              bool                 `json:"known"`
+	Flags              []string             `json:"flags,omitempty"`
+}
+
+type vcExtensionServer struct {
+	URL        string   `json:"url"`
+	Company    string   `json:"company,omitempty"`
+	Type       string   `json:"type,omitempty"`
+	AdminEmail []string `json:"admin_email,omitempty"`
+	Thumbprint string   `json:"thumbprint,omitempty"`
+}
+
+type vcExtensionClient struct {
+	URL     string `json:"url"`
+	Version string `json:"version,omitempty"`
+	Company string `json:"company,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+// ListExtensions list every registered extension and client plugin, flag those not in bundled known vendor list.
+func (vsc *vSphereClient) ListExtensions(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if vsc.vmwSoapClient.ServiceContent.ExtensionManager == nil {
+		return ErrExtensionMgrNotAvailable
+	}
+	var known vcKnownExtensions
+	err := json.Unmarshal(vcKnownExtensionsRaw, &known)
+	if err != nil {
+		return err
+	}
+	tmpCtx := context.Background()
+	extMgr := object.NewExtensionManager(vsc.vmwSoapClient)
+	exts, err := extMgr.List(tmpCtx)
+	if err != nil {
+		return err
+	}
+	vcbi.Extensions = make([]*vcExtension, 0, len(exts))
+	flaggedCnt := 0
+	for i := range exts {
+		ve := convertExtension2External(&exts[i], &known)
+		if len(ve.Flags) != 0 {
+			flaggedCnt++
+			log.Warnf("Extension flagged: %s (%s), %s", ve.Key, ve.Company, strings.Join(ve.Flags, "; "))
+		}
+		vcbi.Extensions = append(vcbi.Extensions, ve)
+	}
+	log.Infof("%d extensions retrieved, %d flagged.", len(exts), flaggedCnt)
+	return nil
+}
+
+func convertExtension2External(ext *types.Extension, known *vcKnownExtensions) *vcExtension {
+	res := &vcExtension{
+		Key:                ext.Key,
+		Company:            ext.Company,
+		Type:               ext.Type,
+		Version:            ext.Version,
+		SolutionUser:       ext.SubjectName,
+		LastHeartbeatTime:  ext.LastHeartbeatTime,
+		ShownInSolutionMgr: ext.ShownInSolutionManager != nil && *ext.ShownInSolutionManager,
+	}
+	if ext.Description != nil {
+		res.Label = ext.Description.GetDescription().Label
+		res.Summary = ext.Description.GetDescription().Summary
+	}
+	if ext.HealthInfo != nil {
+		res.HealthURL = ext.HealthInfo.Url
+	}
+	for _, s := range ext.Server {
+		res.Servers = append(res.Servers, &vcExtensionServer{
+			URL:        s.Url,
+			Company:    s.Company,
+			Type:       s.Type,
+			AdminEmail: s.AdminEmail,
+			Thumbprint: s.ServerThumbprint,
+		})
+		if isPlainHTTPURL(s.Url) {
+			res.Flags = append(res.Flags, "server url is not https: "+s.Url)
+		}
+	}
+	for _, c := range ext.Client {
+		res.ClientPlugins = append(res.ClientPlugins, &vcExtensionClient{
+			URL:     c.Url,
+			Version: c.Version,
+			Company: c.Company,
+			Type:    c.Type,
+		})
+		if isPlainHTTPURL(c.Url) {
+			res.Flags = append(res.Flags, "client plugin url is not https: "+c.Url)
+		}
+	}
+	for _, p := range ext.PrivilegeList {
+		res.PrivilegeIds = append(res.PrivilegeIds, p.PrivID)
+	}
+	res.Known = isKnownExtension(ext.Key, ext.Company, known)
+	if !res.Known {
+		res.Flags = append(res.Flags, "extension key and company are not in known vendor list")
+	}
+	return res
+}
+
+func isKnownExtension(key string, company string, known *vcKnownExtensions) bool {
+	for _, p := range known.KeyPrefixes {
+		if strings.HasPrefix(key, p) {
+			return true
+		}
+	}
+	for _, c := range known.Companies {
+		if company != "" && strings.EqualFold(strings.TrimSpace(company), c) {
+			return true
+		}
+	}
+	return false
+}
+
+func isPlainHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Scheme, "http")
+}
@@ -0,0 +1,309 @@
+package vsphere_api
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrRoleListEmpty = errors.New("role list is empty, run ListPermissions first")
+)
+
+var (
+	//go:embed vc_risky_privileges.json
+	vcRiskyPrivilegesRaw []byte
+)
+
+const (
+	// vcAdminRoleId is the built-in Administrator role, which holds every privilege
+	vcAdminRoleId = -1
+)
+
+type vcRiskyPrivileges struct {
+	// DefaultRoles are roles shipped with vCenter, including sample roles and solution roles
+	DefaultRoles       []*vcDefaultRoleDef       `json:"default_roles"`
+	HighRiskPrivileges []*vcHighRiskPrivilegeDef `json:"high_risk_privileges"`
+}
+
+type vcDefaultRoleDef struct {
+	Name string `json:"name"`
+	// Privileges are expected privilege patterns, same format as vcHighRiskPrivilegeDef.Pattern, "*" matches all.
+	// nil means no baseline, since solution roles change a lot between releases.
+	Privileges []string `json:"privileges"`
+	// Excluded are patterns never expected, even if matched by Privileges
+	Excluded []string `json:"excluded,omitempty"`
+}
+
+type vcHighRiskPrivilegeDef struct {
+	// Pattern is either exact privilege id or group prefix ends with ".*"
+	Pattern string `json:"pattern"`
+	Reason  string `json:"reason"`
+}
+
+type VCRoleAnalysis struct {
+	Roles []*vcRoleAnalysisEntry `json:"roles"`
+	// HighRiskHolders list every permission granting a role with high-risk privileges, Administrator included
+	HighRiskHolders []*vcRoleHolder `json:"high_risk_holders,omitempty"`
+}
+
+type vcRoleAnalysisEntry struct {
+	RoleId int32  `json:"role_id"`
+	Name   string `json:"name"`
+	// Kind is one of: system, default, custom
+	Kind string `json:"kind"`
+	// ClosestDefaultRole is the default role with most similar privilege set, only for custom roles
+	ClosestDefaultRole string  `json:"closest_default_role,omitempty"`
+	Similarity         float64 `json:"similarity,omitempty"`
+	// ExtraPrivileges are privileges not in ClosestDefaultRole
+	ExtraPrivileges []string `json:"extra_privileges,omitempty"`
+	// UnexpectedPrivileges are privileges not in expected set, only for default and system roles with baseline
+	UnexpectedPrivileges []string               `json:"unexpected_privileges,omitempty"`
+	PrivilegeCount       int                    `json:"privilege_count"`
+	HighRiskPrivileges   []*vcHighRiskPrivilege `json:"high_risk_privileges,omitempty"`
+	Holders              []*vcRoleHolder        `json:"holders,omitempty"`
+	Flags                []string               `json:"flags,omitempty"`
+}
+
+type vcHighRiskPrivilege struct {
+	Privilege string `json:"privilege"`
+	Pattern   string `json:"pattern"`
+	Reason    string `json:"reason"`
+}
+
+type vcRoleHolder struct {
+	Principal string `json:"principal"`
+	IsGroup   bool   `json:"is_group"`
+	Entity    string `json:"entity"`
+	Propagate bool   `json:"propagate"`
+	RoleId    int32  `json:"role_id"`
+	RoleName  string `json:"role_name"`
+}
+
+// AnalyzeRoles compare roles and permissions from ListPermissions against default roles, list high-risk privileges
+// in each role and who holds them on which entity. Default roles are matched by name, so their privileges are
+// checked against expected set as well, since built-in role can be modified or recreated under the same name.
+func AnalyzeRoles(vcbi *VCBasicInfo) error {
+	if len(vcbi.VCAuthoriRole) == 0 {
+		return ErrRoleListEmpty
+	}
+	var risky vcRiskyPrivileges
+	err := json.Unmarshal(vcRiskyPrivilegesRaw, &risky)
+	if err != nil {
+		return err
+	}
+	defaultDefs := make(map[string]*vcDefaultRoleDef)
+	for _, d := range risky.DefaultRoles {
+		defaultDefs[d.Name] = d
+	}
+	holdersByRole := make(map[int32][]*vcRoleHolder)
+	for _, p := range vcbi.VCAuthoriPerm {
+		// conversion failure leaves nil in permission list
+		if p == nil {
+			continue
+		}
+		holdersByRole[p.RoleId] = append(holdersByRole[p.RoleId], &vcRoleHolder{
+			Principal: p.Principal,
+			IsGroup:   p.IsGroup,
+			Entity:    p.Entity,
+			Propagate: p.Propagate,
+			RoleId:    p.RoleId,
+		})
+	}
+	// untampered default roles are the reference to compare custom roles with
+	defaultRoles := make([]*vcAuthorizationRole, 0)
+	unexpectedByRole := make(map[int32][]string)
+	for _, r := range vcbi.VCAuthoriRole {
+		d, isDefault := defaultDefs[r.Name]
+		if !r.System && !isDefault {
+			continue
+		}
+		if isDefault {
+			unexpectedByRole[r.RoleId] = unexpectedPrivileges(r.Privileges, d)
+		}
+		if len(unexpectedByRole[r.RoleId]) == 0 {
+			defaultRoles = append(defaultRoles, r)
+		}
+	}
+	res := &VCRoleAnalysis{Roles: make([]*vcRoleAnalysisEntry, 0, len(vcbi.VCAuthoriRole))}
+	flaggedCnt := 0
+	for _, r := range vcbi.VCAuthoriRole {
+		ra := &vcRoleAnalysisEntry{
+			RoleId:             r.RoleId,
+			Name:               r.Name,
+			PrivilegeCount:     len(r.Privileges),
+			HighRiskPrivileges: matchHighRiskPrivileges(r.Privileges, risky.HighRiskPrivileges),
+			Holders:            holdersByRole[r.RoleId],
+		}
+		for _, h := range ra.Holders {
+			h.RoleName = r.Name
+		}
+		switch {
+		case r.System:
+			ra.Kind = "system"
+		case defaultDefs[r.Name] != nil:
+			ra.Kind = "default"
+		default:
+			ra.Kind = "custom"
+			closest, sim := closestDefaultRole(r, defaultRoles)
+			if closest != nil {
+				ra.ClosestDefaultRole = closest.Name
+				ra.Similarity = sim
+				ra.ExtraPrivileges = privilegeDifference(r.Privileges, closest.Privileges)
+			}
+		}
+		// system roles with known name are checked as well
+		ra.UnexpectedPrivileges = unexpectedByRole[r.RoleId]
+		ra.Flags = roleAnalysisFlags(ra, risky.HighRiskPrivileges)
+		if len(ra.HighRiskPrivileges) != 0 || r.RoleId == vcAdminRoleId {
+			res.HighRiskHolders = append(res.HighRiskHolders, ra.Holders...)
+		}
+		if len(ra.Flags) != 0 {
+			flaggedCnt++
+			log.Warnf("Role flagged: %s (%d), %s", ra.Name, ra.RoleId, strings.Join(ra.Flags, "; "))
+		}
+		res.Roles = append(res.Roles, ra)
+	}
+	sort.SliceStable(res.HighRiskHolders, func(i, j int) bool {
+		return res.HighRiskHolders[i].Principal < res.HighRiskHolders[j].Principal
+	})
+	vcbi.RoleAnalysis = res
+	log.Infof("%d roles analyzed, %d flagged, %d high-risk permission assignments.", len(res.Roles), flaggedCnt,
+		len(res.HighRiskHolders))
+	return nil
+}
+
+func roleAnalysisFlags(ra *vcRoleAnalysisEntry, highRiskDefs []*vcHighRiskPrivilegeDef) []string {
+	res := make([]string, 0)
+	if len(ra.UnexpectedPrivileges) != 0 {
+		res = append(res, "default role has privileges not in its expected set: "+
+			strings.Join(ra.UnexpectedPrivileges, ", "))
+		if hr := matchHighRiskPrivileges(ra.UnexpectedPrivileges, highRiskDefs); len(hr) != 0 {
+			hrIds := make([]string, len(hr))
+			for i := range hr {
+				hrIds[i] = hr[i].Privilege
+			}
+			res = append(res, "default role is possibly tampered, unexpected high-risk privileges: "+
+				strings.Join(hrIds, ", "))
+		}
+	}
+	if ra.Kind == "custom" && len(ra.HighRiskPrivileges) != 0 {
+		res = append(res, "custom role with high-risk privileges")
+		if len(ra.Holders) == 0 {
+			res = append(res, "custom role with high-risk privileges is not assigned, possibly prepared for later use")
+		}
+	}
+	if ra.Kind != "system" && len(ra.HighRiskPrivileges) != 0 {
+		for _, h := range ra.Holders {
+			// root folder inventory path is "/"
+			if h.Entity == "/" && h.Propagate {
+				res = append(res, "high-risk role granted on root folder with propagation")
+				break
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// privilegeMatches check privilege id against pattern, which is either exact id, group prefix ends with ".*",
+// or "*" for any privilege
+func privilegeMatches(priv string, pattern string) bool {
+	if pattern == "*" {
+		return true
+	}
+	if strings.HasSuffix(pattern, ".*") {
+		return strings.HasPrefix(priv, strings.TrimSuffix(pattern, "*"))
+	}
+	return priv == pattern
+}
+
+// unexpectedPrivileges return privileges of default role not matched by its expected patterns, or matched by
+// excluded ones. Nothing is returned if the role has no baseline.
+func unexpectedPrivileges(privs []string, d *vcDefaultRoleDef) []string {
+	if d.Privileges == nil {
+		return nil
+	}
+	var res []string
+	for _, p := range privs {
+		expected := false
+		for _, pattern := range d.Privileges {
+			if privilegeMatches(p, pattern) {
+				expected = true
+				break
+			}
+		}
+		for _, pattern := range d.Excluded {
+			if privilegeMatches(p, pattern) {
+				expected = false
+				break
+			}
+		}
+		if !expected {
+			res = append(res, p)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func matchHighRiskPrivileges(privs []string, defs []*vcHighRiskPrivilegeDef) []*vcHighRiskPrivilege {
+	var res []*vcHighRiskPrivilege
+	for _, p := range privs {
+		for _, d := range defs {
+			if privilegeMatches(p, d.Pattern) {
+				res = append(res, &vcHighRiskPrivilege{Privilege: p, Pattern: d.Pattern, Reason: d.Reason})
+				break
+			}
+		}
+	}
+	return res
+}
+
+// closestDefaultRole find the default role with highest Jaccard similarity of privilege set
+func closestDefaultRole(r *vcAuthorizationRole, defaults []*vcAuthorizationRole) (*vcAuthorizationRole, float64) {
+	var best *vcAuthorizationRole
+	bestSim := -1.0
+	rSet := make(map[string]bool)
+	for _, p := range r.Privileges {
+		rSet[p] = true
+	}
+	for _, d := range defaults {
+		inter := 0
+		for _, p := range d.Privileges {
+			if rSet[p] {
+				inter++
+			}
+		}
+		union := len(rSet) + len(d.Privileges) - inter
+		sim := 0.0
+		if union != 0 {
+			sim = float64(inter) / float64(union)
+		}
+		if sim > bestSim {
+			best, bestSim = d, sim
+		}
+	}
+	return best, bestSim
+}
+
+// privilegeDifference return privileges in a but not in b
+func privilegeDifference(a []string, b []string) []string {
+	bSet := make(map[string]bool)
+	for _, p := range b {
+		bSet[p] = true
+	}
+	var res []string
+	for _, p := range a {
+		if !bSet[p] {
+			res = append(res, p)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,175 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	ErrScheduledTaskMgrNotAvailable = errors.New("scheduled task manager is not available, only vCenter supported")
+)
+
+type vcScheduledTask struct {
+	Name          string     `json:"name"`
+	MoRef         string     `json:"moref"`
+	Description   string     `json:"description,omitempty"`
+	Enabled       bool       `json:"enabled"`
+	SchedulerType string     `json:"scheduler_type"`
+	Schedule      string     `json:"schedule"`
+	ActiveTime    *time.Time `json:"active_time,omitempty"`
+	ExpireTime    *time.Time `json:"expire_time,omitempty"`
+	ActionType    string     `json:"action_type"`
+	Action        string     `json:"action"`
+	Notification  string     `json:"notification,omitempty"`
+	// Owner is the last user who created or modified this task, the task runs as this user
+	Owner            string     `json:"owner"`
+	LastModifiedTime time.Time  `json:"last_modified_time"`
+	PrevRunTime      *time.Time `json:"prev_run_time,omitempty"`
+	NextRunTime      *time.Time `json:"next_run_time,omitempty"`
+	State            string     `json:"state"`
+	LastResult       string     `json:"last_result,omitempty"`
+	LastError        string     `json:"last_error,omitempty"`
+}
+
+// ListScheduledTasks retrieve all scheduled tasks from ScheduledTaskManager, grouped by entity inventory path.
+func (vsc *vSphereClient) ListScheduledTasks(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if vsc.vmwSoapClient.ServiceContent.ScheduledTaskManager == nil {
+		return ErrScheduledTaskMgrNotAvailable
+	}
+	tmpCtx := context.Background()
+	coll := property.DefaultCollector(vsc.vmwSoapClient)
+	var stMgr mo.ScheduledTaskManager
+	err := coll.RetrieveOne(tmpCtx, *vsc.vmwSoapClient.ServiceContent.ScheduledTaskManager,
+		[]string{"scheduledTask"}, &stMgr)
+	if err != nil {
+		return err
+	}
+	vcbi.ScheduledTasks = make(map[string][]*vcScheduledTask)
+	if len(stMgr.ScheduledTask) == 0 {
+		log.Infoln("no scheduled task found.")
+		return nil
+	}
+	var sTasks []mo.ScheduledTask
+	err = coll.Retrieve(tmpCtx, stMgr.ScheduledTask, []string{"info"}, &sTasks)
+	if err != nil {
+		return err
+	}
+	pathCache := make(invtPathCache)
+	for i := range sTasks {
+		entPath := pathCache.Resolve(sTasks[i].Info.Entity)
+		vcbi.ScheduledTasks[entPath] = append(vcbi.ScheduledTasks[entPath],
+			convertScheduledTaskInfo2External(&sTasks[i].Info))
+	}
+	log.Infof("%d scheduled tasks retrieved.", len(sTasks))
+	return nil
+}
+
+func convertScheduledTaskInfo2External(sti *types.ScheduledTaskInfo) *vcScheduledTask {
+	res := &vcScheduledTask{
+		Name:             sti.Name,
+		MoRef:            sti.ScheduledTask.Value,
+		Description:      sti.Description,
+		Enabled:          sti.Enabled,
+		Notification:     sti.Notification,
+		Owner:            sti.LastModifiedUser,
+		LastModifiedTime: sti.LastModifiedTime,
+		PrevRunTime:      sti.PrevRunTime,
+		NextRunTime:      sti.NextRunTime,
+		State:            string(sti.State),
+	}
+	if sti.Scheduler != nil {
+		res.SchedulerType = reflect.TypeOf(sti.Scheduler).Elem().Name()
+		res.Schedule = describeTaskScheduler(sti.Scheduler)
+		ts := sti.Scheduler.GetTaskScheduler()
+		res.ActiveTime = ts.ActiveTime
+		res.ExpireTime = ts.ExpireTime
+	}
+	if sti.Action != nil {
+		res.ActionType = reflect.TypeOf(sti.Action).Elem().Name()
+		res.Action = describeScheduledTaskAction(sti.Action)
+	}
+	if sti.Result != nil {
+		res.LastResult = fmt.Sprintf("%v", sti.Result)
+	}
+	if sti.Error != nil {
+		res.LastError = sti.Error.LocalizedMessage
+		if res.LastError == "" && sti.Error.Fault != nil {
+			res.LastError = reflect.TypeOf(sti.Error.Fault).Elem().Name()
+		}
+	}
+	return res
+}
+
+// describeTaskScheduler convert scheduler spec to human-readable string.
+func describeTaskScheduler(bts types.BaseTaskScheduler) string {
+	switch s := bts.(type) {
+	case *types.AfterStartupTaskScheduler:
+		return fmt.Sprintf("%d minute(s) after vCenter startup", s.Minute)
+	case *types.OnceTaskScheduler:
+		if s.RunAt == nil {
+			return "once, immediately"
+		}
+		return "once at " + s.RunAt.Format(time.RFC3339)
+	case *types.HourlyTaskScheduler:
+		return fmt.Sprintf("every %d hour(s) at minute %d", s.Interval, s.Minute)
+	case *types.DailyTaskScheduler:
+		return fmt.Sprintf("every %d day(s) at %02d:%02d", s.Interval, s.Hour, s.Minute)
+	case *types.WeeklyTaskScheduler:
+		days := make([]string, 0, 7)
+		for i, enabled := range []bool{s.Sunday, s.Monday, s.Tuesday, s.Wednesday, s.Thursday, s.Friday,
+			s.Saturday} {
+			if enabled {
+				days = append(days, time.Weekday(i).String())
+			}
+		}
+		return fmt.Sprintf("every %d week(s) on %s at %02d:%02d", s.Interval, strings.Join(days, ","),
+			s.Hour, s.Minute)
+	case *types.MonthlyByDayTaskScheduler:
+		return fmt.Sprintf("every %d month(s) on day %d at %02d:%02d", s.Interval, s.Day, s.Hour, s.Minute)
+	case *types.MonthlyByWeekdayTaskScheduler:
+		return fmt.Sprintf("every %d month(s) on %s %s at %02d:%02d", s.Interval, s.Offset, s.Weekday,
+			s.Hour, s.Minute)
+	default:
+		return reflect.TypeOf(bts).Elem().Name()
+	}
+}
+
+func describeScheduledTaskAction(ba types.BaseAction) string {
+	switch a := ba.(type) {
+	case *types.MethodAction:
+		args := make([]string, 0, len(a.Argument))
+		for _, arg := range a.Argument {
+			if arg.Value == nil {
+				args = append(args, "null")
+				continue
+			}
+			argBytes, err := json.Marshal(arg.Value)
+			if err != nil {
+				args = append(args, fmt.Sprintf("%v", arg.Value))
+				continue
+			}
+			args = append(args, string(argBytes))
+		}
+		return a.Name + "(" + strings.Join(args, ", ") + ")"
+	case *types.RunScriptAction:
+		return a.Script
+	case *types.SendEmailAction:
+		return fmt.Sprintf("to=%s cc=%s subject=%s", a.ToList, a.CcList, a.Subject)
+	case *types.CreateTaskAction:
+		return a.TaskTypeId
+	default:
+		return reflect.TypeOf(ba).Elem().Name()
+	}
+}
@@ -0,0 +1,249 @@
+package vsphere_api
+
+import (
+	"context"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/ssoadmin"
+	"sort"
+	"strings"
+)
+
+var (
+	ErrSSOGroupListEmpty = errors.New("sso group list is empty, run ListAllUsers first")
+)
+
+var (
+	// ssoPrivilegedGroups are built-in groups of system domain granting administrative access to SSO, appliance or
+	// vCenter, matched by name without domain
+	ssoPrivilegedGroups = []string{"Administrators", "LicenseService.Administrators",
+		"SystemConfiguration.Administrators", "SystemConfiguration.BashShellAdministrators", "CAAdmins",
+		"ComponentManager.Administrators", "ActAsUsers", "TrustedAdmins", "ServiceProviderUsers",
+		"RegistryAdministrator", "SyncUsers", "NsxAdministrators", "SolutionUsers"}
+)
+
+const (
+	// ssoPrincipalSearchLimit overrides default limit (100) of ssoadmin search while expanding group members
+	ssoPrincipalSearchLimit = 10000
+)
+
+type vcGroupMembers struct {
+	Users         []string `json:"users,omitempty"`
+	SolutionUsers []string `json:"solution_users,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+}
+
+type VCSSOGroupGraph struct {
+	// Edges are nesting relations, child group is a direct member of parent group
+	Edges            []*vcGroupEdge       `json:"edges,omitempty"`
+	PrivilegedGroups []*vcPrivilegedGroup `json:"privileged_groups,omitempty"`
+}
+
+type vcGroupEdge struct {
+	Parent string `json:"parent"`
+	Child  string `json:"child"`
+}
+
+type vcPrivilegedGroup struct {
+	Name             string               `json:"name"`
+	Reasons          []string             `json:"reasons"`
+	EffectiveMembers []*vcEffectiveMember `json:"effective_members,omitempty"`
+	Flags            []string             `json:"flags,omitempty"`
+}
+
+type vcEffectiveMember struct {
+	Name string `json:"name"`
+	// Kind is one of: user, solution_user
+	Kind string `json:"kind"`
+	// Via is the chain of nested groups from privileged group to the one member belongs to, empty if direct
+	Via   []string `json:"via,omitempty"`
+	Flags []string `json:"flags,omitempty"`
+}
+
+// ssoGroupMembers are direct members of a SSO group, by normalized principal name
+type ssoGroupMembers struct {
+	Users         []string
+	SolutionUsers []string
+	Groups        []string
+	// err is set when group cannot be expanded, e.g. group from external identity source not reachable
+	err error
+}
+
+// ListSSOGroupMembers collect direct members of every SSO group from ListAllUsers, build group nesting graph, and
+// resolve effective members of built-in privileged groups and groups holding high-risk roles.
+func (vsc *vSphereClient) ListSSOGroupMembers(vcbi *VCBasicInfo) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if len(vcbi.SSOGroups) == 0 {
+		return ErrSSOGroupListEmpty
+	}
+	ssocli, err := vsc.Login2SSOMgmt()
+	if err != nil || ssocli == nil {
+		log.Errorln("cannot create ssoadmin client, err:", err)
+		return err
+	}
+	// privileged groups and why
+	privReasons := make(map[string][]string)
+	roots := make([]string, 0, len(vcbi.SSOGroups))
+	for _, g := range vcbi.SSOGroups {
+		key := normalizePrincipal(g.Name)
+		roots = append(roots, key)
+		for _, pg := range ssoPrivilegedGroups {
+			if strings.EqualFold(strings.SplitN(key, "@", 2)[0], pg) {
+				privReasons[key] = append(privReasons[key], "built-in privileged group")
+				break
+			}
+		}
+	}
+	if vcbi.RoleAnalysis != nil {
+		for _, h := range vcbi.RoleAnalysis.HighRiskHolders {
+			if !h.IsGroup {
+				continue
+			}
+			key := normalizePrincipal(h.Principal)
+			// groups from external identity source are not listed by FindGroups, duplicates are skipped on expanding
+			roots = append(roots, key)
+			privReasons[key] = append(privReasons[key], "granted role "+h.RoleName+" on "+h.Entity)
+		}
+	}
+	membership := make(map[string]*ssoGroupMembers)
+	expandSSOGroups(ssocli, roots, membership)
+	vcbi.ssoMembership = membership
+	for _, g := range vcbi.SSOGroups {
+		gm := membership[normalizePrincipal(g.Name)]
+		if gm == nil {
+			continue
+		}
+		if gm.err != nil {
+			g.MembersError = gm.err.Error()
+			continue
+		}
+		g.Members = &vcGroupMembers{Users: gm.Users, SolutionUsers: gm.SolutionUsers, Groups: gm.Groups}
+	}
+	graph := &VCSSOGroupGraph{}
+	for parent, gm := range membership {
+		for _, child := range gm.Groups {
+			graph.Edges = append(graph.Edges, &vcGroupEdge{Parent: parent, Child: child})
+		}
+	}
+	sort.SliceStable(graph.Edges, func(i, j int) bool {
+		if graph.Edges[i].Parent != graph.Edges[j].Parent {
+			return graph.Edges[i].Parent < graph.Edges[j].Parent
+		}
+		return graph.Edges[i].Child < graph.Edges[j].Child
+	})
+	for key, reasons := range privReasons {
+		pg := &vcPrivilegedGroup{Name: key, Reasons: dedupStrings(reasons)}
+		pg.EffectiveMembers, pg.Flags = effectiveGroupMembers(key, membership)
+		log.Infof("privileged sso group %s has %d effective members.", key, len(pg.EffectiveMembers))
+		graph.PrivilegedGroups = append(graph.PrivilegedGroups, pg)
+	}
+	sort.SliceStable(graph.PrivilegedGroups, func(i, j int) bool {
+		return graph.PrivilegedGroups[i].Name < graph.PrivilegedGroups[j].Name
+	})
+	vcbi.SSOGroupGraph = graph
+	log.Infof("%d sso groups expanded, %d nesting relations, %d privileged groups.", len(membership),
+		len(graph.Edges), len(graph.PrivilegedGroups))
+	return nil
+}
+
+// effectiveGroupMembers walk nested groups breadth first, so the shortest nesting chain is reported for each member.
+func effectiveGroupMembers(root string, membership map[string]*ssoGroupMembers) ([]*vcEffectiveMember, []string) {
+	type walkItem struct {
+		group string
+		via   []string
+	}
+	rootDomain := ""
+	if p := strings.SplitN(root, "@", 2); len(p) == 2 {
+		rootDomain = p[1]
+	}
+	res := make([]*vcEffectiveMember, 0)
+	var flags []string
+	seenMembers := make(map[string]bool)
+	visited := map[string]bool{root: true}
+	queue := []walkItem{{group: root}}
+	for len(queue) != 0 {
+		it := queue[0]
+		queue = queue[1:]
+		gm := membership[it.group]
+		if gm == nil || gm.err != nil {
+			flags = append(flags, "membership of "+it.group+" could not be expanded")
+			continue
+		}
+		addMember := func(name string, kind string) {
+			if seenMembers[name] {
+				return
+			}
+			seenMembers[name] = true
+			em := &vcEffectiveMember{Name: name, Kind: kind, Via: it.via}
+			if len(it.via) != 0 {
+				em.Flags = append(em.Flags, "member via nested group")
+			}
+			if p := strings.SplitN(name, "@", 2); len(p) == 2 && rootDomain != "" && p[1] != rootDomain {
+				em.Flags = append(em.Flags, "member from other identity source")
+			}
+			res = append(res, em)
+		}
+		for _, u := range gm.Users {
+			addMember(u, "user")
+		}
+		for _, u := range gm.SolutionUsers {
+			addMember(u, "solution_user")
+		}
+		for _, sg := range gm.Groups {
+			if visited[sg] {
+				continue
+			}
+			visited[sg] = true
+			via := append(append([]string{}, it.via...), sg)
+			queue = append(queue, walkItem{group: sg, via: via})
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, flags
+}
+
+// expandSSOGroups retrieve direct members of groups and all nested groups into res, groups already in res are not
+// retrieved again.
+func expandSSOGroups(ssocli *ssoadmin.Client, groups []string, res map[string]*ssoGroupMembers) {
+	tmpCtx := context.Background()
+	ssocli.Limit = ssoPrincipalSearchLimit
+	queue := append([]string{}, groups...)
+	for len(queue) != 0 {
+		g := queue[0]
+		queue = queue[1:]
+		if _, ok := res[g]; ok {
+			continue
+		}
+		gm := &ssoGroupMembers{}
+		res[g] = gm
+		users, err := ssocli.FindUsersInGroup(tmpCtx, g, "")
+		if err != nil {
+			log.Errorln("find users in group ", g, ", err: ", err)
+			gm.err = err
+			continue
+		}
+		for _, u := range users {
+			name := strings.ToLower(u.Id.Name + "@" + u.Id.Domain)
+			if u.Kind == "person" {
+				gm.Users = append(gm.Users, name)
+			} else {
+				gm.SolutionUsers = append(gm.SolutionUsers, name)
+			}
+		}
+		subGroups, err := ssocli.FindGroupsInGroup(tmpCtx, g, "")
+		if err != nil {
+			log.Errorln("find groups in group ", g, ", err: ", err)
+			gm.err = err
+			continue
+		}
+		for _, sg := range subGroups {
+			sgName := strings.ToLower(sg.Id.Name + "@" + sg.Id.Domain)
+			gm.Groups = append(gm.Groups, sgName)
+			queue = append(queue, sgName)
+		}
+	}
+}
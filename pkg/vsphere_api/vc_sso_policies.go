@@ -0,0 +1,415 @@
+package vsphere_api
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/ssoadmin"
+	ssometd "github.com/vmware/govmomi/ssoadmin/methods"
+	ssotypes "github.com/vmware/govmomi/ssoadmin/types"
+	"github.com/vmware/govmomi/vim25/soap"
+	"github.com/vmware/govmomi/vim25/types"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var (
+	// ldapsProbeTimeout limits TLS handshake with LDAPS server to retrieve its certificate
+	ldapsProbeTimeout = 10 * time.Second
+)
+
+type VCSSOPolicies struct {
+	SystemDomain string `json:"system_domain"`
+	// DefaultDomains are identity sources used when user logs in without domain
+	DefaultDomains     []string                 `json:"default_domains,omitempty"`
+	PasswordPolicy     *vcSSOPasswordPolicy     `json:"password_policy,omitempty"`
+	PasswordExpiration *vcSSOPasswordExpiration `json:"password_expiration,omitempty"`
+	LockoutPolicy      *vcSSOLockoutPolicy      `json:"lockout_policy,omitempty"`
+	TokenPolicy        *vcSSOTokenPolicy        `json:"token_policy,omitempty"`
+	IdentitySources    []*vcSSOIdentitySource   `json:"identity_sources,omitempty"`
+	// LDAPSTrustedCertificates are what SSO validates LDAPS servers of all identity sources against
+	LDAPSTrustedCertificates []*vcCertificate `json:"ldaps_trusted_certificates,omitempty"`
+	Smtp                     *vcSSOSmtpConfig `json:"smtp,omitempty"`
+	Flags                    []string         `json:"flags,omitempty"`
+}
+
+type vcSSOPasswordPolicy struct {
+	Description                      string `json:"description"`
+	MinLength                        int32  `json:"min_length"`
+	MaxLength                        int32  `json:"max_length"`
+	MinAlphabeticCount               int32  `json:"min_alphabetic_count"`
+	MinUppercaseCount                int32  `json:"min_uppercase_count"`
+	MinLowercaseCount                int32  `json:"min_lowercase_count"`
+	MinNumericCount                  int32  `json:"min_numeric_count"`
+	MinSpecialCharCount              int32  `json:"min_special_char_count"`
+	MaxIdenticalAdjacentCharacters   int32  `json:"max_identical_adjacent_characters"`
+	ProhibitedPreviousPasswordsCount int32  `json:"prohibited_previous_passwords_count"`
+	// PasswordLifetimeDays is 0 if password never expires
+	PasswordLifetimeDays int32 `json:"password_lifetime_days"`
+}
+
+type vcSSOPasswordExpiration struct {
+	EmailNotificationEnabled bool    `json:"email_notification_enabled"`
+	EmailFrom                string  `json:"email_from,omitempty"`
+	EmailSubject             string  `json:"email_subject,omitempty"`
+	NotificationDays         []int32 `json:"notification_days,omitempty"`
+}
+
+type vcSSOLockoutPolicy struct {
+	Description              string `json:"description"`
+	MaxFailedAttempts        int32  `json:"max_failed_attempts"`
+	FailedAttemptIntervalSec int64  `json:"failed_attempt_interval_sec"`
+	// AutoUnlockIntervalSec is 0 if locked account must be unlocked by administrator
+	AutoUnlockIntervalSec int64 `json:"auto_unlock_interval_sec"`
+}
+
+type vcSSOTokenPolicy struct {
+	MaxBearerTokenLifetimeMs int64 `json:"max_bearer_token_lifetime_ms"`
+	MaxHoKTokenLifetimeMs    int64 `json:"max_hok_token_lifetime_ms"`
+	ClockToleranceMs         int64 `json:"clock_tolerance_ms"`
+	DelegationCount          int32 `json:"delegation_count"`
+	RenewCount               int32 `json:"renew_count"`
+}
+
+type vcSSOIdentitySource struct {
+	Name                 string `json:"name"`
+	Type                 string `json:"type"`
+	Alias                string `json:"alias,omitempty"`
+	FriendlyName         string `json:"friendly_name,omitempty"`
+	UserBaseDn           string `json:"user_base_dn,omitempty"`
+	GroupBaseDn          string `json:"group_base_dn,omitempty"`
+	PrimaryURL           string `json:"primary_url,omitempty"`
+	FailoverURL          string `json:"failover_url,omitempty"`
+	SearchTimeoutSeconds int32  `json:"search_timeout_seconds,omitempty"`
+	AuthenticationType   string `json:"authentication_type,omitempty"`
+	BindUser             string `json:"bind_user,omitempty"`
+	IsDefault            bool   `json:"is_default"`
+	// ProbedCertificates are presented by LDAPS servers to this host via direct TLS handshake, only when probing is
+	// requested, they are not necessarily what SSO sees or trusts
+	ProbedCertificates []*vcCertificate `json:"probed_certificates,omitempty"`
+	ProbeErrors        []string         `json:"probe_errors,omitempty"`
+}
+
+type vcSSOSmtpConfig struct {
+	Host         string `json:"host,omitempty"`
+	Port         int32  `json:"port,omitempty"`
+	Authenticate bool   `json:"authenticate"`
+	User         string `json:"user,omitempty"`
+}
+
+// getDefaultDomainsRequest and getDaysRemainingRequest are not shipped with govmomi ssoadmin
+type getDefaultDomainsRequest struct {
+	This types.ManagedObjectReference `xml:"_this"`
+}
+
+type getDefaultDomainsResponse struct {
+	Returnval []string `xml:"returnval,omitempty"`
+}
+
+type getDefaultDomainsBody struct {
+	Req    *getDefaultDomainsRequest  `xml:"urn:sso GetDefaultDomains,omitempty"`
+	Res    *getDefaultDomainsResponse `xml:"urn:sso GetDefaultDomainsResponse,omitempty"`
+	Fault_ *soap.Fault                `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault,omitempty"`
+}
+
+func (b *getDefaultDomainsBody) Fault() *soap.Fault { return b.Fault_ }
+
+type getDaysRemainingRequest struct {
+	This   types.ManagedObjectReference `xml:"_this"`
+	UserId ssotypes.PrincipalId         `xml:"userId"`
+}
+
+type getDaysRemainingResponse struct {
+	Returnval int64 `xml:"returnval"`
+}
+
+type getDaysRemainingBody struct {
+	Req    *getDaysRemainingRequest  `xml:"urn:sso GetDaysRemainingUntilPasswordExpiration,omitempty"`
+	Res    *getDaysRemainingResponse `xml:"urn:sso GetDaysRemainingUntilPasswordExpirationResponse,omitempty"`
+	Fault_ *soap.Fault               `xml:"http://schemas.xmlsoap.org/soap/envelope/ Fault,omitempty"`
+}
+
+func (b *getDaysRemainingBody) Fault() *soap.Fault { return b.Fault_ }
+
+// ListSSOPolicies collect password, lockout and token policies, identity sources with certificates trusted for LDAPS,
+// SMTP configuration of SSO, and password expiration of every person user in system domain from ListAllUsers. If
+// probeLDAPS is set, LDAPS servers are connected from this host to record certificates they present.
+func (vsc *vSphereClient) ListSSOPolicies(vcbi *VCBasicInfo, probeLDAPS bool) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	ssocli, err := vsc.Login2SSOMgmt()
+	if err != nil || ssocli == nil {
+		log.Errorln("cannot create ssoadmin client, err:", err)
+		return err
+	}
+	sc := ssocli.ServiceContent
+	res := &VCSSOPolicies{}
+	// password policy
+	ppResp, err := ssometd.GetLocalPasswordPolicy(tmpCtx, ssocli, &ssotypes.GetLocalPasswordPolicy{
+		This: sc.PasswordPolicyService})
+	if err != nil {
+		log.Errorln("get local password policy, err: ", err)
+	} else {
+		pp := ppResp.Returnval
+		res.PasswordPolicy = &vcSSOPasswordPolicy{
+			Description:                      pp.Description,
+			MinLength:                        pp.PasswordFormat.LengthRestriction.MinLength,
+			MaxLength:                        pp.PasswordFormat.LengthRestriction.MaxLength,
+			MinAlphabeticCount:               pp.PasswordFormat.AlphabeticRestriction.MinAlphabeticCount,
+			MinUppercaseCount:                pp.PasswordFormat.AlphabeticRestriction.MinUppercaseCount,
+			MinLowercaseCount:                pp.PasswordFormat.AlphabeticRestriction.MinLowercaseCount,
+			MinNumericCount:                  pp.PasswordFormat.MinNumericCount,
+			MinSpecialCharCount:              pp.PasswordFormat.MinSpecialCharCount,
+			MaxIdenticalAdjacentCharacters:   pp.PasswordFormat.MaxIdenticalAdjacentCharacters,
+			ProhibitedPreviousPasswordsCount: pp.ProhibitedPreviousPasswordsCount,
+			PasswordLifetimeDays:             pp.PasswordLifetimeDays,
+		}
+	}
+	peResp, err := ssometd.GetPasswordExpirationConfiguration(tmpCtx, ssocli,
+		&ssotypes.GetPasswordExpirationConfiguration{This: sc.ConfigurationManagementService})
+	if err != nil {
+		log.Errorln("get password expiration configuration, err: ", err)
+	} else {
+		res.PasswordExpiration = &vcSSOPasswordExpiration{
+			EmailNotificationEnabled: peResp.Returnval.EmailNotificationEnabled,
+			EmailFrom:                peResp.Returnval.EmailFrom,
+			EmailSubject:             peResp.Returnval.EmailSubject,
+			NotificationDays:         peResp.Returnval.NotificationDays,
+		}
+	}
+	// lockout policy
+	lpResp, err := ssometd.GetLockoutPolicy(tmpCtx, ssocli, &ssotypes.GetLockoutPolicy{This: sc.LockoutPolicyService})
+	if err != nil {
+		log.Errorln("get lockout policy, err: ", err)
+	} else {
+		res.LockoutPolicy = &vcSSOLockoutPolicy{
+			Description:              lpResp.Returnval.Description,
+			MaxFailedAttempts:        lpResp.Returnval.MaxFailedAttempts,
+			FailedAttemptIntervalSec: lpResp.Returnval.FailedAttemptIntervalSec,
+			AutoUnlockIntervalSec:    lpResp.Returnval.AutoUnlockIntervalSec,
+		}
+	}
+	res.TokenPolicy = getSSOTokenPolicy(ssocli)
+	// identity sources
+	err = collectSSOIdentitySources(ssocli, res, probeLDAPS)
+	if err != nil {
+		log.Errorln("get identity sources, err: ", err)
+	}
+	res.LDAPSTrustedCertificates, err = getLDAPSTrustedCertificates(ssocli)
+	if err != nil {
+		log.Errorln("get ldaps trusted certificates, err: ", err)
+	}
+	// smtp
+	smtpResp, err := ssometd.GetSmtpConfiguration(tmpCtx, ssocli, &ssotypes.GetSmtpConfiguration{
+		This: sc.SmtpManagementService})
+	if err != nil {
+		log.Errorln("get smtp configuration, err: ", err)
+	} else {
+		res.Smtp = &vcSSOSmtpConfig{
+			Host:         smtpResp.Returnval.Host,
+			Port:         smtpResp.Returnval.Port,
+			Authenticate: smtpResp.Returnval.Authenticate != nil && *smtpResp.Returnval.Authenticate,
+			User:         smtpResp.Returnval.User,
+		}
+	}
+	// password expiration of person users, only system domain users are managed by sso
+	lifetimeDays := int32(0)
+	if res.PasswordPolicy != nil {
+		lifetimeDays = res.PasswordPolicy.PasswordLifetimeDays
+	}
+	sysDomainSuffix := "@" + strings.ToLower(res.SystemDomain)
+	for _, u := range vcbi.SSOUsers {
+		if u.IsSolutionUser || res.SystemDomain == "" || !strings.HasSuffix(strings.ToLower(u.Name), sysDomainSuffix) {
+			continue
+		}
+		fillPasswordExpiration(ssocli, u, lifetimeDays)
+	}
+	res.Flags = ssoPolicyFlags(res)
+	for _, f := range res.Flags {
+		log.Warnln("SSO policy flagged: ", f)
+	}
+	vcbi.SSOPolicies = res
+	return nil
+}
+
+func getSSOTokenPolicy(ssocli *ssoadmin.Client) *vcSSOTokenPolicy {
+	tmpCtx := context.Background()
+	sysMgmt := ssocli.ServiceContent.SystemManagementService
+	res := &vcSSOTokenPolicy{}
+	bearerResp, err := ssometd.GetMaximumBearerTokenLifetime(tmpCtx, ssocli,
+		&ssotypes.GetMaximumBearerTokenLifetime{This: sysMgmt})
+	if err != nil {
+		log.Errorln("get token policy, err: ", err)
+		return nil
+	}
+	res.MaxBearerTokenLifetimeMs = bearerResp.Returnval
+	hokResp, err := ssometd.GetMaximumHoKTokenLifetime(tmpCtx, ssocli,
+		&ssotypes.GetMaximumHoKTokenLifetime{This: sysMgmt})
+	if err == nil {
+		res.MaxHoKTokenLifetimeMs = hokResp.Returnval
+	}
+	ctResp, err := ssometd.GetClockTolerance(tmpCtx, ssocli, &ssotypes.GetClockTolerance{This: sysMgmt})
+	if err == nil {
+		res.ClockToleranceMs = ctResp.Returnval
+	}
+	dcResp, err := ssometd.GetDelegationCount(tmpCtx, ssocli, &ssotypes.GetDelegationCount{This: sysMgmt})
+	if err == nil {
+		res.DelegationCount = dcResp.Returnval
+	}
+	rcResp, err := ssometd.GetRenewCount(tmpCtx, ssocli, &ssotypes.GetRenewCount{This: sysMgmt})
+	if err == nil {
+		res.RenewCount = rcResp.Returnval
+	}
+	return res
+}
+
+func collectSSOIdentitySources(ssocli *ssoadmin.Client, res *VCSSOPolicies, probeLDAPS bool) error {
+	tmpCtx := context.Background()
+	domMgmt := ssocli.ServiceContent.DomainManagementService
+	var defResp getDefaultDomainsBody
+	err := ssocli.RoundTrip(tmpCtx, &getDefaultDomainsBody{Req: &getDefaultDomainsRequest{This: domMgmt}}, &defResp)
+	if err != nil {
+		log.Errorln("get default domains, err: ", err)
+	} else if defResp.Res != nil {
+		res.DefaultDomains = defResp.Res.Returnval
+	}
+	isDefault := func(name string) bool {
+		for _, d := range res.DefaultDomains {
+			if strings.EqualFold(d, name) {
+				return true
+			}
+		}
+		return false
+	}
+	domResp, err := ssometd.GetDomains(tmpCtx, ssocli, &ssotypes.GetDomains{This: domMgmt})
+	if err != nil {
+		return err
+	}
+	if domResp.Returnval == nil {
+		return nil
+	}
+	res.SystemDomain = domResp.Returnval.SystemDomainName
+	res.IdentitySources = append(res.IdentitySources, &vcSSOIdentitySource{
+		Name:      res.SystemDomain,
+		Type:      "System Domain",
+		IsDefault: isDefault(res.SystemDomain),
+	})
+	for _, d := range domResp.Returnval.ExternalDomains {
+		is := &vcSSOIdentitySource{
+			Name:                 d.Name,
+			Type:                 d.Type,
+			Alias:                d.Alias,
+			FriendlyName:         d.Details.FriendlyName,
+			UserBaseDn:           d.Details.UserBaseDn,
+			GroupBaseDn:          d.Details.GroupBaseDn,
+			PrimaryURL:           d.Details.PrimaryUrl.String(),
+			FailoverURL:          d.Details.FailoverUrl.String(),
+			SearchTimeoutSeconds: d.Details.SearchTimeoutSeconds,
+			AuthenticationType:   d.AuthenticationDetails.AuthenticationType,
+			BindUser:             d.AuthenticationDetails.Username,
+			IsDefault:            isDefault(d.Name),
+		}
+		for _, u := range []url.URL{d.Details.PrimaryUrl, d.Details.FailoverUrl} {
+			if !probeLDAPS || !strings.EqualFold(u.Scheme, "ldaps") {
+				continue
+			}
+			certs, err := probeLDAPSCertificates(&u)
+			if err != nil {
+				is.ProbeErrors = append(is.ProbeErrors, u.Host+": "+err.Error())
+				continue
+			}
+			for _, c := range certs {
+				is.ProbedCertificates = append(is.ProbedCertificates,
+					convertX509Cert2External(c, "ldaps_server_probe", u.Host))
+			}
+		}
+		res.IdentitySources = append(res.IdentitySources, is)
+	}
+	return nil
+}
+
+// getLDAPSTrustedCertificates read certificates trusted for LDAPS connections of identity sources from SSL
+// certificate manager of SSO configuration management service.
+func getLDAPSTrustedCertificates(ssocli *ssoadmin.Client) ([]*vcCertificate, error) {
+	tmpCtx := context.Background()
+	mgrResp, err := ssometd.GetSslCertificateManager(tmpCtx, ssocli, &ssotypes.GetSslCertificateManager{
+		This: ssocli.ServiceContent.ConfigurationManagementService})
+	if err != nil {
+		return nil, err
+	}
+	certResp, err := ssometd.GetAllCertificates(tmpCtx, ssocli, &ssotypes.GetAllCertificates{This: mgrResp.Returnval})
+	if err != nil {
+		return nil, err
+	}
+	return parseCertStrings2External(certResp.Returnval, "ldaps_trusted", ""), nil
+}
+
+// probeLDAPSCertificates retrieve certificate chain presented by LDAPS server, certificate is not verified since
+// it is only recorded.
+func probeLDAPSCertificates(u *url.URL) ([]*x509.Certificate, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "636")
+	}
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: ldapsProbeTimeout}, "tcp", host,
+		&tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates, nil
+}
+
+func fillPasswordExpiration(ssocli *ssoadmin.Client, u *vcUser, lifetimeDays int32) {
+	p := strings.SplitN(u.Name, "@", 2)
+	if len(p) != 2 {
+		return
+	}
+	var resp getDaysRemainingBody
+	err := ssocli.RoundTrip(context.Background(), &getDaysRemainingBody{Req: &getDaysRemainingRequest{
+		This:   ssocli.ServiceContent.PrincipalManagementService,
+		UserId: ssotypes.PrincipalId{Name: p[0], Domain: p[1]},
+	}}, &resp)
+	if err != nil || resp.Res == nil {
+		log.Debugln("get password expiration of ", u.Name, ", err: ", err)
+		return
+	}
+	days := resp.Res.Returnval
+	u.PasswordExpiresInDays = &days
+	// api does not expose last change time, estimate it from lifetime and remaining days
+	if lifetimeDays > 0 && days >= 0 && days <= int64(lifetimeDays) {
+		lastSet := time.Now().AddDate(0, 0, -int(int64(lifetimeDays)-days)).Truncate(24 * time.Hour)
+		u.PasswordLastSetEstimate = &lastSet
+	}
+}
+
+func ssoPolicyFlags(p *VCSSOPolicies) []string {
+	res := make([]string, 0)
+	if p.PasswordPolicy != nil {
+		if p.PasswordPolicy.MinLength < 8 {
+			res = append(res, "password minimum length is less than 8")
+		}
+		if p.PasswordPolicy.PasswordLifetimeDays == 0 {
+			res = append(res, "local passwords never expire")
+		}
+	}
+	if p.LockoutPolicy != nil && p.LockoutPolicy.MaxFailedAttempts == 0 {
+		res = append(res, "account lockout is disabled")
+	}
+	for _, is := range p.IdentitySources {
+		for _, u := range []string{is.PrimaryURL, is.FailoverURL} {
+			if strings.HasPrefix(strings.ToLower(u), "ldap://") {
+				res = append(res, "identity source "+is.Name+" uses plain ldap: "+u)
+			}
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
@@ -0,0 +1,405 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/vapi/library"
+	"github.com/vmware/govmomi/vapi/tags"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotVCenter = errors.New("target is not vCenter, automation api is not available")
+)
+
+var (
+	// libraryPayloadExts are file extensions of scripts, executables and archives, not expected in library at all
+	libraryPayloadExts = []string{".exe", ".dll", ".msi", ".bat", ".cmd", ".ps1", ".vbs", ".sh", ".py", ".pl", ".jar",
+		".zip", ".7z", ".rar", ".tar", ".tgz", ".gz", ".bz2", ".xz"}
+	// libraryImageExts are bootable images and appliances, normal library content, only flagged together with
+	// recent creation, subscribed source or checksum mismatch
+	libraryImageExts = []string{".iso", ".ova"}
+)
+
+type TagsLibrariesReport struct {
+	CollectedAt time.Time           `json:"collected_at"`
+	Server      string              `json:"server"`
+	Categories  []*vcTagCategory    `json:"categories,omitempty"`
+	Tags        []*vcTag            `json:"tags,omitempty"`
+	Libraries   []*vcContentLibrary `json:"libraries,omitempty"`
+	Errors      []string            `json:"errors,omitempty"`
+}
+
+type vcTagCategory struct {
+	ID              string   `json:"id"`
+	Name            string   `json:"name"`
+	Description     string   `json:"description,omitempty"`
+	Cardinality     string   `json:"cardinality"`
+	AssociableTypes []string `json:"associable_types,omitempty"`
+}
+
+type vcTag struct {
+	ID           string   `json:"id"`
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	CategoryID   string   `json:"category_id"`
+	CategoryName string   `json:"category_name,omitempty"`
+	AttachedTo   []string `json:"attached_to,omitempty"`
+}
+
+type vcContentLibrary struct {
+	ID               string     `json:"id"`
+	Name             string     `json:"name"`
+	Type             string     `json:"type"`
+	Description      string     `json:"description,omitempty"`
+	CreationTime     *time.Time `json:"creation_time,omitempty"`
+	LastModifiedTime *time.Time `json:"last_modified_time,omitempty"`
+	LastSyncTime     *time.Time `json:"last_sync_time,omitempty"`
+	Storage          []string   `json:"storage,omitempty"`
+	// SubscriptionURL is set for subscribed library, content is pulled from this url
+	SubscriptionURL      string           `json:"subscription_url,omitempty"`
+	SubscriptionAuth     string           `json:"subscription_auth,omitempty"`
+	SubscriptionUser     string           `json:"subscription_user,omitempty"`
+	SubscriptionSSLThumb string           `json:"subscription_ssl_thumbprint,omitempty"`
+	AutomaticSync        bool             `json:"automatic_sync,omitempty"`
+	Published            bool             `json:"published,omitempty"`
+	PublishURL           string           `json:"publish_url,omitempty"`
+	PublishAuth          string           `json:"publish_auth,omitempty"`
+	Items                []*vcLibraryItem `json:"items,omitempty"`
+	Flags                []string         `json:"flags,omitempty"`
+}
+
+type vcLibraryItem struct {
+	ID               string           `json:"id"`
+	Name             string           `json:"name"`
+	Type             string           `json:"type,omitempty"`
+	Description      string           `json:"description,omitempty"`
+	Size             int64            `json:"size"`
+	Cached           bool             `json:"cached"`
+	CreationTime     *time.Time       `json:"creation_time,omitempty"`
+	LastModifiedTime *time.Time       `json:"last_modified_time,omitempty"`
+	LastSyncTime     *time.Time       `json:"last_sync_time,omitempty"`
+	SourceID         string           `json:"source_id,omitempty"`
+	Files            []*vcLibraryFile `json:"files,omitempty"`
+	FilesError       string           `json:"files_error,omitempty"`
+	Flags            []string         `json:"flags,omitempty"`
+}
+
+type vcLibraryFile struct {
+	Name              string `json:"name"`
+	Size              int64  `json:"size"`
+	Cached            bool   `json:"cached"`
+	Version           string `json:"version,omitempty"`
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+	Checksum          string `json:"checksum,omitempty"`
+}
+
+// CollectTagsAndLibraries enumerate tag categories, tags with attached objects, content libraries, their items and
+// item files via vSphere Automation API, save to json and library files to csv.
+func (vsc *vSphereClient) CollectTagsAndLibraries() error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	if !vsc.IsVCenter() {
+		return ErrNotVCenter
+	}
+	rc, err := vsc.Login2REST()
+	if err != nil {
+		return err
+	}
+	report := &TagsLibrariesReport{
+		CollectedAt: time.Now(),
+		Server:      vsc.vmwSoapClient.URL().Host,
+	}
+	pathCache := make(invtPathCache)
+	err = collectTags(tags.NewManager(rc), report, pathCache)
+	if err != nil {
+		log.Errorln("collect tags, err: ", err)
+		report.Errors = append(report.Errors, "tags: "+err.Error())
+	}
+	err = collectContentLibraries(library.NewManager(rc), report)
+	if err != nil {
+		log.Errorln("collect content libraries, err: ", err)
+		report.Errors = append(report.Errors, "content libraries: "+err.Error())
+	}
+	itemCnt := 0
+	for _, l := range report.Libraries {
+		itemCnt += len(l.Items)
+		for _, f := range l.Flags {
+			log.Warnf("Content library %s flagged: %s", l.Name, f)
+		}
+		for _, it := range l.Items {
+			for _, f := range it.Flags {
+				log.Warnf("Library item %s/%s flagged: %s", l.Name, it.Name, f)
+			}
+		}
+	}
+	log.Infof("%d categories, %d tags, %d content libraries with %d items retrieved.", len(report.Categories),
+		len(report.Tags), len(report.Libraries), itemCnt)
+	fPath, err := SaveJSONOutput("TagsLibraries", report)
+	if err != nil {
+		log.Errorln("save tags and libraries json, err: ", err)
+		return err
+	}
+	log.Infoln("tags and libraries stored in json: ", fPath)
+	return saveLibraryFilesCSV(report.Libraries)
+}
+
+func collectTags(tagMgr *tags.Manager, report *TagsLibrariesReport, pathCache invtPathCache) error {
+	tmpCtx := context.Background()
+	cats, err := tagMgr.GetCategories(tmpCtx)
+	if err != nil {
+		return err
+	}
+	catNames := make(map[string]string)
+	for _, c := range cats {
+		report.Categories = append(report.Categories, &vcTagCategory{
+			ID:              c.ID,
+			Name:            c.Name,
+			Description:     c.Description,
+			Cardinality:     c.Cardinality,
+			AssociableTypes: c.AssociableTypes,
+		})
+		catNames[c.ID] = c.Name
+	}
+	sort.SliceStable(report.Categories, func(i, j int) bool {
+		return report.Categories[i].Name < report.Categories[j].Name
+	})
+	tagList, err := tagMgr.GetTags(tmpCtx)
+	if err != nil {
+		return err
+	}
+	if len(tagList) == 0 {
+		return nil
+	}
+	tagIds := make([]string, 0, len(tagList))
+	tagById := make(map[string]*vcTag)
+	for _, t := range tagList {
+		vt := &vcTag{
+			ID:           t.ID,
+			Name:         t.Name,
+			Description:  t.Description,
+			CategoryID:   t.CategoryID,
+			CategoryName: catNames[t.CategoryID],
+		}
+		tagIds = append(tagIds, t.ID)
+		tagById[t.ID] = vt
+		report.Tags = append(report.Tags, vt)
+	}
+	sort.SliceStable(report.Tags, func(i, j int) bool {
+		if report.Tags[i].CategoryName != report.Tags[j].CategoryName {
+			return report.Tags[i].CategoryName < report.Tags[j].CategoryName
+		}
+		return report.Tags[i].Name < report.Tags[j].Name
+	})
+	attached, err := tagMgr.ListAttachedObjectsOnTags(tmpCtx, tagIds)
+	if err != nil {
+		return err
+	}
+	for _, ao := range attached {
+		vt, ok := tagById[ao.TagID]
+		if !ok {
+			continue
+		}
+		for _, obj := range ao.ObjectIDs {
+			vt.AttachedTo = append(vt.AttachedTo, pathCache.Resolve(obj.Reference()))
+		}
+		sort.Strings(vt.AttachedTo)
+	}
+	return nil
+}
+
+func collectContentLibraries(libMgr *library.Manager, report *TagsLibrariesReport) error {
+	tmpCtx := context.Background()
+	libs, err := libMgr.GetLibraries(tmpCtx)
+	if err != nil {
+		return err
+	}
+	for _, l := range libs {
+		vl := &vcContentLibrary{
+			ID:               l.ID,
+			Name:             l.Name,
+			Type:             l.Type,
+			Description:      l.Description,
+			CreationTime:     l.CreationTime,
+			LastModifiedTime: l.LastModifiedTime,
+			LastSyncTime:     l.LastSyncTime,
+		}
+		for _, sb := range l.Storage {
+			vl.Storage = append(vl.Storage, sb.Type+":"+sb.DatastoreID)
+		}
+		// passwords are never recorded
+		if sub := l.Subscription; sub != nil {
+			vl.SubscriptionURL = sub.SubscriptionURL
+			vl.SubscriptionAuth = sub.AuthenticationMethod
+			vl.SubscriptionUser = sub.UserName
+			vl.SubscriptionSSLThumb = sub.SslThumbprint
+			vl.AutomaticSync = sub.AutomaticSyncEnabled != nil && *sub.AutomaticSyncEnabled
+		}
+		if pub := l.Publication; pub != nil {
+			vl.Published = pub.Published != nil && *pub.Published
+			vl.PublishURL = pub.PublishURL
+			vl.PublishAuth = pub.AuthenticationMethod
+		}
+		items, err := libMgr.GetLibraryItems(tmpCtx, l.ID)
+		if err != nil {
+			log.Errorln("get items of library ", l.Name, ", err: ", err)
+			report.Errors = append(report.Errors, "items of library "+l.Name+": "+err.Error())
+		}
+		for _, it := range items {
+			vi := &vcLibraryItem{
+				ID:               it.ID,
+				Name:             it.Name,
+				Type:             it.Type,
+				Description:      it.Description,
+				Size:             it.Size,
+				Cached:           it.Cached,
+				CreationTime:     it.CreationTime,
+				LastModifiedTime: it.LastModifiedTime,
+				LastSyncTime:     it.LastSyncTime,
+				SourceID:         it.SourceID,
+			}
+			files, err := libMgr.ListLibraryItemFiles(tmpCtx, it.ID)
+			if err != nil {
+				log.Errorln("list files of library item ", it.Name, ", err: ", err)
+				vi.FilesError = err.Error()
+			}
+			for _, f := range files {
+				vf := &vcLibraryFile{
+					Name:    f.Name,
+					Cached:  f.Cached != nil && *f.Cached,
+					Version: f.Version,
+				}
+				if f.Size != nil {
+					vf.Size = *f.Size
+				}
+				if f.Checksum != nil {
+					vf.ChecksumAlgorithm = f.Checksum.Algorithm
+					vf.Checksum = f.Checksum.Checksum
+				}
+				vi.Files = append(vi.Files, vf)
+			}
+			vl.Items = append(vl.Items, vi)
+		}
+		sort.SliceStable(vl.Items, func(i, j int) bool {
+			return vl.Items[i].Name < vl.Items[j].Name
+		})
+		vl.Flags = contentLibraryFlags(vl)
+		report.Libraries = append(report.Libraries, vl)
+	}
+	sort.SliceStable(report.Libraries, func(i, j int) bool {
+		return report.Libraries[i].Name < report.Libraries[j].Name
+	})
+	// source item of subscribed library item may be in any library
+	filesByItem := make(map[string][]*vcLibraryFile)
+	for _, vl := range report.Libraries {
+		for _, vi := range vl.Items {
+			filesByItem[vi.ID] = vi.Files
+		}
+	}
+	for _, vl := range report.Libraries {
+		for _, vi := range vl.Items {
+			vi.Flags = libraryItemFlags(vi, vl, filesByItem[vi.SourceID], report.CollectedAt)
+		}
+	}
+	return nil
+}
+
+func contentLibraryFlags(l *vcContentLibrary) []string {
+	res := make([]string, 0)
+	if l.SubscriptionURL != "" {
+		res = append(res, "subscribed library pulls content from "+l.SubscriptionURL)
+		if strings.HasPrefix(strings.ToLower(l.SubscriptionURL), "http://") {
+			res = append(res, "subscription url uses plain http")
+		}
+	}
+	if l.Published && strings.EqualFold(l.PublishAuth, "NONE") {
+		res = append(res, "library is published without authentication")
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// libraryItemFlags flag scripts, executables and archives in item, images only if item is recent, from subscribed
+// library, or its checksum differs from the same file of source item (sourceFiles, if source item is known).
+func libraryItemFlags(it *vcLibraryItem, lib *vcContentLibrary, sourceFiles []*vcLibraryFile, now time.Time) []string {
+	res := make([]string, 0)
+	recent := it.CreationTime != nil && now.Sub(*it.CreationTime) < 30*24*time.Hour
+	if recent {
+		res = append(res, "item created in last 30 days")
+	}
+	mismatched := make(map[string]bool)
+	for _, f := range it.Files {
+		for _, sf := range sourceFiles {
+			if sf.Name == f.Name && sf.Checksum != "" && f.Checksum != "" &&
+				strings.EqualFold(sf.ChecksumAlgorithm, f.ChecksumAlgorithm) && !strings.EqualFold(sf.Checksum, f.Checksum) {
+				mismatched[f.Name] = true
+				res = append(res, "checksum of file "+f.Name+" differs from source item")
+			}
+		}
+	}
+	hasExt := func(name string, exts []string) bool {
+		for _, ext := range exts {
+			if strings.HasSuffix(strings.ToLower(name), ext) {
+				return true
+			}
+		}
+		return false
+	}
+	for _, f := range it.Files {
+		switch {
+		case hasExt(f.Name, libraryPayloadExts):
+			res = append(res, "file is script, executable or archive: "+f.Name)
+		case hasExt(f.Name, libraryImageExts) && (recent || lib.SubscriptionURL != "" || mismatched[f.Name]):
+			res = append(res, "image file may carry payload: "+f.Name)
+		}
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+func saveLibraryFilesCSV(libs []*vcContentLibrary) error {
+	wDstFilePath := filepath.Join("output", "LibraryFiles_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Library", "LibraryType", "SubscriptionURL", "Item", "ItemType", "ItemCreationTime",
+		"File", "Size", "ChecksumAlgorithm", "Checksum", "Cached", "Flags"})
+	if err != nil {
+		return err
+	}
+	for _, l := range libs {
+		for _, it := range l.Items {
+			created := ""
+			if it.CreationTime != nil {
+				created = it.CreationTime.Format(time.RFC3339)
+			}
+			for _, f := range it.Files {
+				err = cwr.Write([]string{l.Name, l.Type, l.SubscriptionURL, it.Name, it.Type, created, f.Name,
+					strconv.FormatInt(f.Size, 10), f.ChecksumAlgorithm, f.Checksum, strconv.FormatBool(f.Cached),
+					strings.Join(it.Flags, "AND")})
+				if err != nil {
+					log.Errorln("csv write error:", err)
+				}
+			}
+		}
+	}
+	log.Infoln("library files stored in csv: ", wDstFilePath)
+	return nil
+}
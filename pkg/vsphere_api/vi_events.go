@@ -231,6 +231,48 @@ func (vsc *vSphereClient) GetEventsFromMgr(lightMode bool, dcList []types.Manage
 	return nil
 }
 
+// collectEventsByTypeId read all events with specific type id under baseRef recursively, beginTime and endTime
+// are optional, if both are nil, all events kept by server will be returned. This is used by collectors which only
+// care about few types of events.
+func (vsc *vSphereClient) collectEventsByTypeId(baseRef types.ManagedObjectReference, typeIds []string,
+	beginTime *time.Time, endTime *time.Time) ([]types.BaseEvent, error) {
+	if vsc.evntMgr == nil || !vsc.postInitDone {
+		return nil, ErrPrerequisitesNotSatisfied
+	}
+	tmpCtx := context.Background()
+	filterSpec := types.EventFilterSpec{
+		Entity: &types.EventFilterSpecByEntity{
+			Entity:    baseRef,
+			Recursion: types.EventFilterSpecRecursionOptionAll,
+		},
+		EventTypeId: typeIds,
+	}
+	if beginTime != nil || endTime != nil {
+		filterSpec.Time = &types.EventFilterSpecByTime{
+			BeginTime: beginTime,
+			EndTime:   endTime,
+		}
+	}
+	collector, err := vsc.evntMgr.CreateCollectorForEvents(tmpCtx, filterSpec)
+	if err != nil {
+		return nil, err
+	}
+	defer collector.Destroy(tmpCtx)
+	res := make([]types.BaseEvent, 0)
+	for {
+		events, err := collector.ReadNextEvents(tmpCtx, 500)
+		if err != nil {
+			return res, err
+		}
+		if len(events) == 0 {
+			break
+		}
+		res = append(res, events...)
+	}
+	log.Debugf("collectEventsByTypeId: %d events read.", len(res))
+	return res, nil
+}
+
 func (vsc *vSphereClient) NewVcsaOptionManager() error {
 	vsc.vcsaOptionMgr = object.NewOptionManager(vsc.vmwSoapClient, *vsc.vmwSoapClient.ServiceContent.Setting)
 	return nil
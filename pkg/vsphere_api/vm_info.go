@@ -0,0 +1,291 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// vmInfoPropSet only contains properties used by collector, do not retrieve the whole VirtualMachine object
+	vmInfoPropSet = []string{"name", "config", "runtime", "guest", "snapshot", "resourcePool", "datastore", "network"}
+)
+
+type VMInfo struct {
+	Name          string `json:"name"`
+	InventoryPath string `json:"inventory_path"`
+	MoRef         string `json:"moref"`
+	// config
+	UUID         string            `json:"uuid,omitempty"`
+	InstanceUUID string            `json:"instance_uuid,omitempty"`
+	GuestId      string            `json:"guest_id,omitempty"`
+	GuestOS      string            `json:"guest_os,omitempty"`
+	VMXPath      string            `json:"vmx_path,omitempty"`
+	LogDirectory string            `json:"log_directory,omitempty"`
+	CreateDate   *time.Time        `json:"create_date,omitempty"`
+	ChangeVer    string            `json:"change_version,omitempty"`
+	Annotation   string            `json:"annotation,omitempty"`
+	IsTemplate   bool              `json:"is_template"`
+	HWVersion    string            `json:"hw_version,omitempty"`
+	NumCPU       int32             `json:"num_cpu,omitempty"`
+	MemoryMB     int32             `json:"memory_mb,omitempty"`
+	Devices      []*VMDevice       `json:"devices,omitempty"`
+	ExtraConfig  map[string]string `json:"extra_config,omitempty"`
+	// runtime
+	Host            string     `json:"host,omitempty"`
+	PowerState      string     `json:"power_state,omitempty"`
+	ConnectionState string     `json:"connection_state,omitempty"`
+	BootTime        *time.Time `json:"boot_time,omitempty"`
+	SuspendTime     *time.Time `json:"suspend_time,omitempty"`
+	NumMksConns     int32      `json:"num_mks_connections"`
+	// guest
+	GuestHostName      string         `json:"guest_hostname,omitempty"`
+	GuestIPAddr        string         `json:"guest_ip_addr,omitempty"`
+	GuestNICs          []*VMGuestNIC  `json:"guest_nics,omitempty"`
+	ToolsStatus        string         `json:"tools_status,omitempty"`
+	ToolsRunningStatus string         `json:"tools_running_status,omitempty"`
+	ToolsVersion       string         `json:"tools_version,omitempty"`
+	GuestState         string         `json:"guest_state,omitempty"`
+	Snapshots          []*VMSnapshotS `json:"snapshots,omitempty"`
+	// placement
+	ResourcePool string   `json:"resource_pool,omitempty"`
+	Datastores   []string `json:"datastores,omitempty"`
+	Networks     []string `json:"networks,omitempty"`
+}
+
+type VMDevice struct {
+	Key         int32  `json:"key"`
+	Type        string `json:"type"`
+	Label       string `json:"label,omitempty"`
+	Summary     string `json:"summary,omitempty"`
+	BackingFile string `json:"backing_file,omitempty"`
+	Connected   *bool  `json:"connected,omitempty"`
+}
+
+type VMGuestNIC struct {
+	Network   string   `json:"network,omitempty"`
+	MacAddr   string   `json:"mac_addr,omitempty"`
+	IpAddrs   []string `json:"ip_addrs,omitempty"`
+	Connected bool     `json:"connected"`
+}
+
+// VMSnapshotS is a flattened summary of snapshot tree node, detailed enumeration is done by snapshot collector.
+type VMSnapshotS struct {
+	Name       string    `json:"name"`
+	Id         int32     `json:"id"`
+	CreateTime time.Time `json:"create_time"`
+	State      string    `json:"state"`
+}
+
+// RetrieveVMInventory use property collector to fetch all virtual machines with necessary properties.
+func (vsc *vSphereClient) RetrieveVMInventory() ([]mo.VirtualMachine, error) {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return nil, ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(tmpCtx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"VirtualMachine"}, true)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpCtx)
+	}()
+	var vms []mo.VirtualMachine
+	err = ctnrView.Retrieve(tmpCtx, []string{"VirtualMachine"}, vmInfoPropSet, &vms)
+	if err != nil {
+		return nil, err
+	}
+	if len(vms) == 0 {
+		log.Warn("Retrieved data length of Virtual Machine List is zero.")
+	}
+	log.Debugf("Retrieved %d Virtual Machines.", len(vms))
+	return vms, nil
+}
+
+// CollectVMInfo retrieve full virtual machine inventory, save as JSON and flat CSV summary.
+func (vsc *vSphereClient) CollectVMInfo() error {
+	vms, err := vsc.RetrieveVMInventory()
+	if err != nil {
+		log.Errorln("retrieve vm inventory, err: ", err)
+		return err
+	}
+	pathCache := make(invtPathCache)
+	res := make([]*VMInfo, 0, len(vms))
+	for i := range vms {
+		res = append(res, convertVirtualMachine2External(&vms[i], pathCache))
+	}
+	log.Infoln("vm inventory converted, total: ", len(res))
+	fPath, err := SaveJSONOutput("VMInfo", res)
+	if err != nil {
+		log.Errorln("save vm info json, err: ", err)
+		return err
+	}
+	log.Infoln("vm info stored in json: ", fPath)
+	err = saveVMInfoCSV(res)
+	if err != nil {
+		log.Errorln("save vm info csv, err: ", err)
+		return err
+	}
+	return nil
+}
+
+func saveVMInfoCSV(vmis []*VMInfo) error {
+	wDstFilePath := filepath.Join("output", "VMInfo_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Name", "Inventory Path", "UUID", "Instance UUID", "Guest OS", "VMX Path",
+		"Create Date", "Power State", "Host", "Boot Time", "Guest Hostname", "Guest IPs", "Tools Status",
+		"Snapshot Count", "Resource Pool", "Datastores", "Annotation"})
+	if err != nil {
+		return err
+	}
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return "-"
+		}
+		return t.Format(time.RFC3339)
+	}
+	for _, v := range vmis {
+		guestIPs := make([]string, 0)
+		for _, n := range v.GuestNICs {
+			guestIPs = append(guestIPs, n.IpAddrs...)
+		}
+		err = cwr.Write([]string{v.Name, v.InventoryPath, v.UUID, v.InstanceUUID, v.GuestOS, v.VMXPath,
+			formatTime(v.CreateDate), v.PowerState, v.Host, formatTime(v.BootTime), v.GuestHostName,
+			strings.Join(guestIPs, "AND"), v.ToolsStatus, strconv.Itoa(len(v.Snapshots)), v.ResourcePool,
+			strings.Join(v.Datastores, "AND"), v.Annotation})
+		if err != nil {
+			log.Errorln("csv write error:", err)
+			continue
+		}
+	}
+	log.Infoln("vm info stored in csv: ", wDstFilePath)
+	return nil
+}
+
+func convertVirtualMachine2External(vm *mo.VirtualMachine, pathCache invtPathCache) *VMInfo {
+	res := &VMInfo{
+		Name:            vm.Name,
+		InventoryPath:   pathCache.Resolve(vm.Self),
+		MoRef:           vm.Self.Value,
+		Host:            "-",
+		PowerState:      string(vm.Runtime.PowerState),
+		ConnectionState: string(vm.Runtime.ConnectionState),
+		BootTime:        vm.Runtime.BootTime,
+		SuspendTime:     vm.Runtime.SuspendTime,
+		NumMksConns:     vm.Runtime.NumMksConnections,
+	}
+	if vm.Runtime.Host != nil {
+		res.Host = pathCache.Resolve(*vm.Runtime.Host)
+	}
+	if vm.Config != nil {
+		res.UUID = vm.Config.Uuid
+		res.InstanceUUID = vm.Config.InstanceUuid
+		res.GuestId = vm.Config.GuestId
+		res.GuestOS = vm.Config.GuestFullName
+		res.VMXPath = vm.Config.Files.VmPathName
+		res.LogDirectory = vm.Config.Files.LogDirectory
+		res.CreateDate = vm.Config.CreateDate
+		res.ChangeVer = vm.Config.ChangeVersion
+		res.Annotation = vm.Config.Annotation
+		res.IsTemplate = vm.Config.Template
+		res.HWVersion = vm.Config.Version
+		res.NumCPU = vm.Config.Hardware.NumCPU
+		res.MemoryMB = vm.Config.Hardware.MemoryMB
+		res.Devices = convertVirtualDevices2External(vm.Config.Hardware.Device)
+		if len(vm.Config.ExtraConfig) != 0 {
+			res.ExtraConfig = make(map[string]string, len(vm.Config.ExtraConfig))
+			for _, bov := range vm.Config.ExtraConfig {
+				ov := bov.GetOptionValue()
+				res.ExtraConfig[ov.Key] = fmt.Sprintf("%v", ov.Value)
+			}
+		}
+	}
+	if vm.Guest != nil {
+		res.GuestHostName = vm.Guest.HostName
+		res.GuestIPAddr = vm.Guest.IpAddress
+		res.ToolsStatus = string(vm.Guest.ToolsStatus)
+		res.ToolsRunningStatus = vm.Guest.ToolsRunningStatus
+		res.ToolsVersion = vm.Guest.ToolsVersion
+		res.GuestState = vm.Guest.GuestState
+		for _, n := range vm.Guest.Net {
+			res.GuestNICs = append(res.GuestNICs, &VMGuestNIC{
+				Network:   n.Network,
+				MacAddr:   n.MacAddress,
+				IpAddrs:   n.IpAddress,
+				Connected: n.Connected,
+			})
+		}
+	}
+	if vm.Snapshot != nil {
+		res.Snapshots = flattenSnapshotTree(vm.Snapshot.RootSnapshotList)
+	}
+	if vm.ResourcePool != nil {
+		res.ResourcePool = pathCache.Resolve(*vm.ResourcePool)
+	}
+	for _, ds := range vm.Datastore {
+		res.Datastores = append(res.Datastores, pathCache.Resolve(ds))
+	}
+	for _, n := range vm.Network {
+		res.Networks = append(res.Networks, pathCache.Resolve(n))
+	}
+	return res
+}
+
+func flattenSnapshotTree(trees []types.VirtualMachineSnapshotTree) []*VMSnapshotS {
+	res := make([]*VMSnapshotS, 0)
+	for _, t := range trees {
+		res = append(res, &VMSnapshotS{
+			Name:       t.Name,
+			Id:         t.Id,
+			CreateTime: t.CreateTime,
+			State:      string(t.State),
+		})
+		res = append(res, flattenSnapshotTree(t.ChildSnapshotList)...)
+	}
+	return res
+}
+
+func convertVirtualDevices2External(devs []types.BaseVirtualDevice) []*VMDevice {
+	if len(devs) == 0 {
+		return nil
+	}
+	res := make([]*VMDevice, len(devs))
+	for i := range devs {
+		d := devs[i].GetVirtualDevice()
+		vmd := &VMDevice{
+			Key:  d.Key,
+			Type: reflect.TypeOf(devs[i]).Elem().Name(),
+		}
+		if d.DeviceInfo != nil {
+			vmd.Label = d.DeviceInfo.GetDescription().Label
+			vmd.Summary = d.DeviceInfo.GetDescription().Summary
+		}
+		if fb, ok := d.Backing.(types.BaseVirtualDeviceFileBackingInfo); ok {
+			vmd.BackingFile = fb.GetVirtualDeviceFileBackingInfo().FileName
+		}
+		if d.Connectable != nil {
+			vmd.Connected = &d.Connectable.Connected
+		}
+		res[i] = vmd
+	}
+	return res
+}
@@ -0,0 +1,250 @@
+package vsphere_api
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/list"
+	"github.com/vmware/govmomi/object"
+	"github.com/vmware/govmomi/property"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	ErrVMLogDirUnknown = errors.New("log directory of virtual machine is unknown")
+)
+
+var (
+	// vmware.log line formats:
+	// before 7.0: 2020-01-02T03:04:05.678Z| vmx| I125: message
+	// since 7.0:  2021-01-02T03:04:05.678Z In(05) vmx - message
+	vmLogLineRegexOld = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\S+?)\| (\S+)\| ([A-Z]\d+): ?(.*)$`)
+	vmLogLineRegexNew = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2}T\S+) ([A-Za-z]{2}\(\d+\)) (\S+) - ?(.*)$`)
+)
+
+type VMLogsManifest struct {
+	StartedAt  time.Time `json:"started_at"`
+	FinishedAt time.Time `json:"finished_at"`
+	OutputDir  string    `json:"output_dir"`
+	// VMs is keyed by managed object id, since display name is not unique across folders and datacenters
+	VMs map[string]*VMLogsEntry `json:"vms"`
+}
+
+type VMLogsEntry struct {
+	VMName        string            `json:"vm_name"`
+	InventoryPath string            `json:"inventory_path"`
+	LocalDir      string            `json:"local_dir"`
+	Files         []*DSAcquiredFile `json:"files,omitempty"`
+	Error         string            `json:"error,omitempty"`
+}
+
+type VMLogRecord struct {
+	VMRef     string
+	VMName    string
+	File      string
+	LineNo    int
+	Timestamp string
+	Level     string
+	Thread    string
+	Message   string
+}
+
+// CollectVMLogs locates VM directory from config.files.logDirectory, downloads all vmware*.log files and
+// optionally parse them into timestamped records tagged with VM name.
+func (vsc *vSphereClient) CollectVMLogs(vmElems []list.Element, parse bool) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	refs := make([]types.ManagedObjectReference, len(vmElems))
+	invtPaths := make(map[types.ManagedObjectReference]string)
+	for i := range vmElems {
+		refs[i] = vmElems[i].Object.Reference()
+		invtPaths[refs[i]] = vmElems[i].Path
+	}
+	var vms []mo.VirtualMachine
+	err := property.DefaultCollector(vsc.vmwSoapClient).Retrieve(tmpCtx, refs,
+		[]string{"name", "config.files", "datastore"}, &vms)
+	if err != nil {
+		return err
+	}
+	// datastore ref to list element, used for browsing, names are only unique within datacenter
+	err = vsc.ListDatastores()
+	if err != nil {
+		return err
+	}
+	allDS, err := vsc.GetCtxData("dsList")
+	if err != nil {
+		return err
+	}
+	dsByRef := make(map[types.ManagedObjectReference]list.Element)
+	for _, dsElem := range allDS.([]list.Element) {
+		dsByRef[dsElem.Object.Reference()] = dsElem
+	}
+	manifest := &VMLogsManifest{
+		StartedAt: time.Now(),
+		OutputDir: filepath.Join("output", "VMLogs_"+strconv.FormatInt(time.Now().Unix(), 10)),
+		VMs:       make(map[string]*VMLogsEntry),
+	}
+	records := make([]*VMLogRecord, 0)
+	for i := range vms {
+		vmRef := vms[i].Self.Value
+		entry := &VMLogsEntry{
+			VMName:        vms[i].Name,
+			InventoryPath: invtPaths[vms[i].Self],
+			LocalDir:      filepath.Join(manifest.OutputDir, vmRef+"_"+safePathComponent(vms[i].Name)),
+		}
+		manifest.VMs[vmRef] = entry
+		acqFiles, err := vsc.acquireVMLogs(&vms[i], dsByRef, entry.LocalDir)
+		if err != nil {
+			log.Errorln("acquire vm logs of ", entry.InventoryPath, ", err: ", err)
+			entry.Error = err.Error()
+			continue
+		}
+		entry.Files = acqFiles
+		log.Infof("vm %s: %d log files acquired.", entry.InventoryPath, len(acqFiles))
+		if !parse {
+			continue
+		}
+		for _, af := range acqFiles {
+			recs, err := parseVMwareLog(vmRef, vms[i].Name, af.LocalPath)
+			if err != nil {
+				log.Errorln("parse vm log ", af.LocalPath, ", err: ", err)
+				continue
+			}
+			records = append(records, recs...)
+		}
+	}
+	manifest.FinishedAt = time.Now()
+	fPath, err := SaveJSONOutput("VMLogs_Manifest", manifest)
+	if err != nil {
+		log.Errorln("save vm logs manifest, err: ", err)
+		return err
+	}
+	log.Infoln("vm logs manifest stored in json: ", fPath)
+	if parse {
+		return saveVMLogRecordsCSV(records)
+	}
+	return nil
+}
+
+// acquireVMLogs download vmware*.log of vm into localDir. Datastore in log directory is resolved among datastores
+// of vm itself, since another datacenter may have datastore of the same name.
+func (vsc *vSphereClient) acquireVMLogs(vm *mo.VirtualMachine, dsByRef map[types.ManagedObjectReference]list.Element,
+	localDir string) ([]*DSAcquiredFile, error) {
+	if vm.Config == nil {
+		return nil, ErrVMLogDirUnknown
+	}
+	// log directory is the same as vmx by default
+	var dsp object.DatastorePath
+	if vm.Config.Files.LogDirectory != "" {
+		if !dsp.FromString(vm.Config.Files.LogDirectory) {
+			return nil, ErrVMLogDirUnknown
+		}
+	} else {
+		if !dsp.FromString(vm.Config.Files.VmPathName) {
+			return nil, ErrVMLogDirUnknown
+		}
+		dsp.Path = path.Dir(dsp.Path)
+	}
+	var dsElem *list.Element
+	for _, ref := range vm.Datastore {
+		if e, ok := dsByRef[ref]; ok && path.Base(e.Path) == dsp.Datastore {
+			dsElem = &e
+			break
+		}
+	}
+	if dsElem == nil {
+		return nil, ErrVMLogDirUnknown
+	}
+	dcPath, err := vsc.datacenterPathOfDatastore(dsElem.Path)
+	if err != nil {
+		return nil, err
+	}
+	files, err := vsc.BrowseDatastoreFiles(dsElem.Object.Reference(), dsp.Datastore,
+		strings.TrimSuffix(dsp.Path, "/")+"/vmware*.log")
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*DSAcquiredFile, 0)
+	for _, f := range files {
+		if f.Type == "FolderFileInfo" {
+			continue
+		}
+		localPath := filepath.Join(localDir, path.Base(dsRelativePath(f.Path)))
+		af, err := vsc.acquireSingleDSFile(dcPath, dsElem.Object.Reference().Value, f, localPath)
+		if err != nil {
+			log.Errorln("acquire vm log ", f.Path, ", err: ", err)
+			continue
+		}
+		res = append(res, af)
+	}
+	return res, nil
+}
+
+// parseVMwareLog parse vmware.log into records, line without timestamp is appended to previous record.
+func parseVMwareLog(vmRef string, vmName string, localPath string) ([]*VMLogRecord, error) {
+	fd, err := os.Open(localPath)
+	if err != nil {
+		return nil, err
+	}
+	defer fd.Close()
+	res := make([]*VMLogRecord, 0)
+	fileName := filepath.Base(localPath)
+	scanner := bufio.NewScanner(fd)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		rec := &VMLogRecord{VMRef: vmRef, VMName: vmName, File: fileName, LineNo: lineNo}
+		if m := vmLogLineRegexOld.FindStringSubmatch(line); m != nil {
+			rec.Timestamp, rec.Thread, rec.Level, rec.Message = m[1], m[2], m[3], m[4]
+		} else if m := vmLogLineRegexNew.FindStringSubmatch(line); m != nil {
+			rec.Timestamp, rec.Level, rec.Thread, rec.Message = m[1], m[2], m[3], m[4]
+		} else if len(res) != 0 {
+			res[len(res)-1].Message += "\n" + line
+			continue
+		} else {
+			rec.Message = line
+		}
+		res = append(res, rec)
+	}
+	return res, scanner.Err()
+}
+
+func saveVMLogRecordsCSV(records []*VMLogRecord) error {
+	wDstFilePath := filepath.Join("output", "VMLogs_Parsed_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Timestamp", "VMRef", "VM", "File", "Line", "Level", "Thread", "Message"})
+	if err != nil {
+		return err
+	}
+	for _, r := range records {
+		err = cwr.Write([]string{r.Timestamp, r.VMRef, r.VMName, r.File, strconv.Itoa(r.LineNo), r.Level, r.Thread,
+			r.Message})
+		if err != nil {
+			log.Errorln("csv write error:", err)
+			continue
+		}
+	}
+	log.Infoln("parsed vm logs stored in csv: ", wDstFilePath)
+	return nil
+}
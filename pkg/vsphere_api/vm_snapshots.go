@@ -0,0 +1,299 @@
+package vsphere_api
+
+import (
+	"context"
+	"encoding/csv"
+	log "github.com/sirupsen/logrus"
+	"github.com/vmware/govmomi/view"
+	"github.com/vmware/govmomi/vim25/mo"
+	"github.com/vmware/govmomi/vim25/types"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// snapshotTaskDescIds are TaskInfo.DescriptionId of snapshot operations, snapshot itself does not have
+	// dedicated event type, so we have to filter TaskEvent.
+	snapshotTaskDescIds = map[string]string{
+		"VirtualMachine.createSnapshot":            "create",
+		"VirtualMachine.createSnapshotEx":          "create",
+		"VirtualMachine.removeAllSnapshots":        "remove_all",
+		"VirtualMachineSnapshot.remove":            "remove",
+		"VirtualMachineSnapshot.revert":            "revert",
+		"VirtualMachine.revertToCurrentSnapshot":   "revert",
+		"VirtualMachine.consolidateDisks":          "consolidate",
+		"VirtualMachineSnapshot.exportSnapshot":    "export",
+		"VirtualMachine.removeAllSnapshotsEx":      "remove_all",
+		"VirtualMachine.revertToCurrentSnapshotEx": "revert",
+	}
+	// snapshotEventMatchSlack is the maximum time delta between create task and snapshot create time
+	snapshotEventMatchSlack = 5 * time.Minute
+)
+
+type VMSnapshotReport struct {
+	WindowStart time.Time            `json:"window_start"`
+	WindowEnd   time.Time            `json:"window_end"`
+	Snapshots   []*VMSnapshotRecord  `json:"snapshots"`
+	Events      []*VMSnapshotEvent   `json:"events,omitempty"`
+	Anomalies   []*VMSnapshotAnomaly `json:"anomalies,omitempty"`
+}
+
+type VMSnapshotRecord struct {
+	VMName           string                       `json:"vm_name"`
+	VMPath           string                       `json:"vm_path"`
+	vmRef            types.ManagedObjectReference `json:"-"`
+	MoRef            string                       `json:"moref"`
+	Id               int32                        `json:"id"`
+	Name             string                       `json:"name"`
+	Description      string                       `json:"description,omitempty"`
+	ParentName       string                       `json:"parent_name,omitempty"`
+	CreateTime       time.Time                    `json:"create_time"`
+	PowerState       string                       `json:"power_state"`
+	MemoryIncluded   bool                         `json:"memory_included"`
+	Quiesced         bool                         `json:"quiesced"`
+	IsCurrent        bool                         `json:"is_current"`
+	BackingFiles     []string                     `json:"backing_files,omitempty"`
+	InIncidentWindow bool                         `json:"in_incident_window"`
+}
+
+type VMSnapshotEvent struct {
+	EventID     int32                         `json:"event_id"`
+	CreatedTime time.Time                     `json:"created_time"`
+	Operation   string                        `json:"operation"`
+	TaskDescId  string                        `json:"task_desc_id"`
+	VMName      string                        `json:"vm_name,omitempty"`
+	vmRef       *types.ManagedObjectReference `json:"-"`
+	UserName    string                        `json:"user_name,omitempty"`
+	TaskState   string                        `json:"task_state,omitempty"`
+}
+
+type VMSnapshotAnomaly struct {
+	Kind     string    `json:"kind"`
+	VMName   string    `json:"vm_name"`
+	Time     time.Time `json:"time"`
+	UserName string    `json:"user_name,omitempty"`
+	Detail   string    `json:"detail"`
+}
+
+// CollectVMSnapshots walks snapshot trees of all VMs, correlates them with snapshot tasks and flags
+// snapshot operations inside incident window.
+func (vsc *vSphereClient) CollectVMSnapshots(windowStart time.Time, windowEnd time.Time) error {
+	if !vsc.IsLoggedIn() || !vsc.postInitDone {
+		return ErrSessionInvalid
+	}
+	tmpCtx := context.Background()
+	viewMgr := view.NewManager(vsc.vmwSoapClient)
+	ctnrView, err := viewMgr.CreateContainerView(tmpCtx, vsc.vmwSoapClient.ServiceContent.RootFolder,
+		[]string{"VirtualMachine"}, true)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = ctnrView.Destroy(tmpCtx)
+	}()
+	var vms []mo.VirtualMachine
+	err = ctnrView.Retrieve(tmpCtx, []string{"VirtualMachine"}, []string{"name", "snapshot", "layoutEx"}, &vms)
+	if err != nil {
+		return err
+	}
+	log.Debugf("CollectVMSnapshots: %d vms retrieved.", len(vms))
+	pathCache := make(invtPathCache)
+	inWindow := func(t time.Time) bool {
+		return !t.Before(windowStart) && !t.After(windowEnd)
+	}
+	report := &VMSnapshotReport{
+		WindowStart: windowStart,
+		WindowEnd:   windowEnd,
+		Snapshots:   make([]*VMSnapshotRecord, 0),
+		Anomalies:   make([]*VMSnapshotAnomaly, 0),
+	}
+	for i := range vms {
+		if vms[i].Snapshot == nil {
+			continue
+		}
+		recs := walkSnapshotTree(&vms[i], vms[i].Snapshot.RootSnapshotList, "", pathCache.Resolve(vms[i].Self))
+		for _, r := range recs {
+			r.InIncidentWindow = inWindow(r.CreateTime)
+			if r.InIncidentWindow {
+				detail := "snapshot " + r.Name + " created in incident window"
+				if r.MemoryIncluded {
+					detail += ", memory included"
+				}
+				report.Anomalies = append(report.Anomalies, &VMSnapshotAnomaly{
+					Kind:   "snapshot_created_in_window",
+					VMName: r.VMName,
+					Time:   r.CreateTime,
+					Detail: detail,
+				})
+			}
+		}
+		report.Snapshots = append(report.Snapshots, recs...)
+	}
+	log.Infoln("snapshot tree walked, total snapshots: ", len(report.Snapshots))
+	// correlate with snapshot tasks, only events inside window are requested
+	evnts, err := vsc.collectEventsByTypeId(vsc.vmwSoapClient.ServiceContent.RootFolder, []string{"TaskEvent"},
+		&windowStart, &windowEnd)
+	if err != nil {
+		log.Errorln("collect snapshot task events, err: ", err)
+	}
+	for _, be := range evnts {
+		te, ok := be.(*types.TaskEvent)
+		if !ok {
+			continue
+		}
+		op, ok := snapshotTaskDescIds[te.Info.DescriptionId]
+		if !ok || !inWindow(te.CreatedTime) {
+			continue
+		}
+		se := &VMSnapshotEvent{
+			EventID:     te.Key,
+			CreatedTime: te.CreatedTime,
+			Operation:   op,
+			TaskDescId:  te.Info.DescriptionId,
+			VMName:      te.Info.EntityName,
+			vmRef:       te.Info.Entity,
+			UserName:    te.UserName,
+			TaskState:   string(te.Info.State),
+		}
+		report.Events = append(report.Events, se)
+		report.Anomalies = append(report.Anomalies, correlateSnapshotEvent(se, report.Snapshots)...)
+	}
+	log.Infoln("snapshot events correlated, total events: ", len(report.Events))
+	fPath, err := SaveJSONOutput("VMSnapshots", report)
+	if err != nil {
+		log.Errorln("save vm snapshots json, err: ", err)
+		return err
+	}
+	log.Infoln("vm snapshots stored in json: ", fPath)
+	return saveVMSnapshotCSV(report.Snapshots)
+}
+
+// correlateSnapshotEvent flags removal inside window, and creation whose snapshot does not exist anymore.
+func correlateSnapshotEvent(se *VMSnapshotEvent, snaps []*VMSnapshotRecord) []*VMSnapshotAnomaly {
+	switch se.Operation {
+	case "remove", "remove_all", "consolidate":
+		return []*VMSnapshotAnomaly{{
+			Kind:     "snapshot_removed_in_window",
+			VMName:   se.VMName,
+			Time:     se.CreatedTime,
+			UserName: se.UserName,
+			Detail:   se.TaskDescId + " executed in incident window",
+		}}
+	case "create":
+		for _, s := range snaps {
+			if se.vmRef == nil || s.vmRef != *se.vmRef {
+				continue
+			}
+			delta := s.CreateTime.Sub(se.CreatedTime)
+			if delta < 0 {
+				delta = -delta
+			}
+			if delta <= snapshotEventMatchSlack {
+				return nil
+			}
+		}
+		return []*VMSnapshotAnomaly{{
+			Kind:     "snapshot_created_then_removed",
+			VMName:   se.VMName,
+			Time:     se.CreatedTime,
+			UserName: se.UserName,
+			Detail:   "snapshot created in incident window but no longer exists",
+		}}
+	}
+	return nil
+}
+
+func walkSnapshotTree(vm *mo.VirtualMachine, trees []types.VirtualMachineSnapshotTree, parentName string,
+	vmPath string) []*VMSnapshotRecord {
+	res := make([]*VMSnapshotRecord, 0)
+	for _, t := range trees {
+		r := &VMSnapshotRecord{
+			VMName:      vm.Name,
+			VMPath:      vmPath,
+			vmRef:       vm.Self,
+			MoRef:       t.Snapshot.Value,
+			Id:          t.Id,
+			Name:        t.Name,
+			Description: t.Description,
+			ParentName:  parentName,
+			CreateTime:  t.CreateTime,
+			PowerState:  string(t.State),
+			Quiesced:    t.Quiesced,
+			IsCurrent:   vm.Snapshot.CurrentSnapshot != nil && *vm.Snapshot.CurrentSnapshot == t.Snapshot,
+		}
+		r.MemoryIncluded, r.BackingFiles = snapshotFilesFromLayout(vm.LayoutEx, t.Snapshot)
+		res = append(res, r)
+		res = append(res, walkSnapshotTree(vm, t.ChildSnapshotList, t.Name, vmPath)...)
+	}
+	return res
+}
+
+// snapshotFilesFromLayout resolves vmsn, vmem and delta disk files of a snapshot using layoutEx file keys.
+func snapshotFilesFromLayout(layout *types.VirtualMachineFileLayoutEx, snapRef types.ManagedObjectReference) (bool, []string) {
+	if layout == nil {
+		return false, nil
+	}
+	fileNames := make(map[int32]string, len(layout.File))
+	for _, f := range layout.File {
+		fileNames[f.Key] = f.Name
+	}
+	for _, sl := range layout.Snapshot {
+		if sl.Key != snapRef {
+			continue
+		}
+		files := make([]string, 0)
+		if n, ok := fileNames[sl.DataKey]; ok {
+			files = append(files, n)
+		}
+		// memoryKey is -1 or unset if memory is not included
+		memIncluded := false
+		if n, ok := fileNames[sl.MemoryKey]; ok && sl.MemoryKey > 0 {
+			memIncluded = true
+			files = append(files, n)
+		}
+		for _, d := range sl.Disk {
+			// only the last unit in chain belongs to this snapshot
+			if len(d.Chain) == 0 {
+				continue
+			}
+			for _, k := range d.Chain[len(d.Chain)-1].FileKey {
+				if n, ok := fileNames[k]; ok {
+					files = append(files, n)
+				}
+			}
+		}
+		return memIncluded, files
+	}
+	return false, nil
+}
+
+func saveVMSnapshotCSV(snaps []*VMSnapshotRecord) error {
+	wDstFilePath := filepath.Join("output", "VMSnapshots_"+strconv.FormatInt(time.Now().Unix(), 10)+".csv")
+	outputFd, err := os.Create(wDstFilePath)
+	if err != nil {
+		return err
+	}
+	defer outputFd.Close()
+	defer outputFd.Sync()
+	cwr := csv.NewWriter(outputFd)
+	defer cwr.Flush()
+	err = cwr.Write([]string{"Timestamp", "VM", "Snapshot", "Parent", "Power State", "Memory Included", "Quiesced",
+		"In Incident Window", "Backing Files", "Description"})
+	if err != nil {
+		return err
+	}
+	for _, s := range snaps {
+		err = cwr.Write([]string{strconv.FormatInt(s.CreateTime.Unix(), 10), s.VMPath, s.Name, s.ParentName,
+			s.PowerState, strconv.FormatBool(s.MemoryIncluded), strconv.FormatBool(s.Quiesced),
+			strconv.FormatBool(s.InIncidentWindow), strings.Join(s.BackingFiles, "AND"), s.Description})
+		if err != nil {
+			log.Errorln("csv write error:", err)
+			continue
+		}
+	}
+	log.Infoln("vm snapshots stored in csv: ", wDstFilePath)
+	return nil
+}